@@ -0,0 +1,101 @@
+package libgosrp
+
+import (
+	"context"
+)
+
+// Drives an SRPSession with encoded handshake messages, for transports
+// that carry bytes rather than message structs. With JSONCodec this
+// speaks the JSON messages earlier versions of the package used.
+type ServerAdapter struct {
+	Session *SRPSession
+	Codec   Codec
+}
+
+func (a ServerAdapter) ReadHello(data []byte) error {
+	return a.ReadHelloContext(context.Background(), data)
+}
+
+// As ReadHello, but see SRPSession.ReadHelloContext.
+func (a ServerAdapter) ReadHelloContext(ctx context.Context, data []byte) error {
+	var hello ClientHello
+	if err := a.Codec.Unmarshal(data, &hello); err != nil {
+		return err
+	}
+
+	return a.Session.ReadHelloContext(ctx, hello)
+}
+
+func (a ServerAdapter) Challenge() ([]byte, error) {
+	challenge, err := a.Session.Challenge()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Codec.Marshal(&challenge)
+}
+
+func (a ServerAdapter) ReadProof(data []byte) error {
+	var p ClientProof
+	if err := a.Codec.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	return a.Session.ReadProof(p)
+}
+
+func (a ServerAdapter) Proof() ([]byte, error) {
+	p, err := a.Session.Proof()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Codec.Marshal(&p)
+}
+
+// Drives an SRPClientSession with encoded handshake messages.
+type ClientAdapter struct {
+	Session *SRPClientSession
+	Codec   Codec
+}
+
+func (a ClientAdapter) Hello() ([]byte, error) {
+	hello, err := a.Session.Hello()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Codec.Marshal(&hello)
+}
+
+func (a ClientAdapter) ReadChallenge(data []byte, p string) error {
+	return a.ReadChallengeContext(context.Background(), data, p)
+}
+
+// As ReadChallenge, but see SRPClientSession.ReadChallengeContext.
+func (a ClientAdapter) ReadChallengeContext(ctx context.Context, data []byte, p string) error {
+	var challenge ServerChallenge
+	if err := a.Codec.Unmarshal(data, &challenge); err != nil {
+		return err
+	}
+
+	return a.Session.ReadChallengeContext(ctx, challenge, p)
+}
+
+func (a ClientAdapter) Proof() ([]byte, error) {
+	p, err := a.Session.Proof()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Codec.Marshal(&p)
+}
+
+func (a ClientAdapter) ReadProof(data []byte) error {
+	var p ServerProof
+	if err := a.Codec.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	return a.Session.ReadProof(p)
+}
@@ -0,0 +1,47 @@
+package libgosrp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// Version byte leading every value sealed by aead_seal.
+const sealFormat = 1
+
+// Returns AES-256-GCM with key, which must be 32 bytes.
+func new_aead(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("Encryption keys must be 32 bytes, not %d.", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Encrypts plaintext bound to ad, returning the format byte, a random
+// nonce and the ciphertext.
+func aead_seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	out := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = sealFormat
+	if _, err := rand.Read(out[1:]); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(out, out[1:], plaintext, ad), nil
+}
+
+// Reverses aead_seal.
+func aead_open(aead cipher.AEAD, value, ad []byte) ([]byte, error) {
+	if len(value) < 1+aead.NonceSize() || value[0] != sealFormat {
+		return nil, ErrorMalformed("unknown encrypted value format")
+	}
+
+	nonce := value[1 : 1+aead.NonceSize()]
+	return aead.Open(nil, nonce, value[1+aead.NonceSize():], ad)
+}
@@ -0,0 +1,79 @@
+package libgosrp
+
+import (
+	"context"
+	"time"
+)
+
+// Receives an Event for each stage of the logins a Server runs, for
+// audit logs and security monitoring. Set with Server.SetObserver.
+// Observe is called synchronously from the Server's methods, so it
+// should be quick, and must be safe for concurrent use.
+type Observer interface {
+	Observe(ctx context.Context, e Event)
+}
+
+// An Observer calling a function.
+type ObserverFunc func(ctx context.Context, e Event)
+
+func (f ObserverFunc) Observe(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+type EventKind string
+
+const (
+	// A challenge was sent to a known user.
+	EventChallenge EventKind = "challenge"
+	// A decoy challenge was sent for a user who doesn't exist.
+	EventUnknownUser EventKind = "unknown_user"
+	// The client's A was 0 mod N, so no challenge was sent.
+	EventInvalidA EventKind = "invalid_a"
+	// The client's proof was wrong, or the user doesn't exist.
+	EventProofFailed EventKind = "proof_failed"
+	// The client's proof was accepted.
+	EventSuccess EventKind = "success"
+	// The client didn't send its proof before the session timed out.
+	EventExpired EventKind = "expired"
+	// The user's verifier was sealed with the current pepper key.
+	// Err is set if storing it failed.
+	EventVerifierUpgraded EventKind = "verifier_upgraded"
+	// A hello or proof was refused by the throttle.
+	EventThrottled EventKind = "throttled"
+)
+
+// What happened at one stage of a login. Events never carry secrets
+// or protocol values, only who and when.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Username the client gave
+	User string
+	// Client address from WithRemoteAddr, if any
+	Addr string
+	// Profile and group of the verifier, or of the server's config
+	// for unknown users
+	Profile string
+	Group   string
+	// Set for every event of a login by a user who doesn't exist
+	UnknownUser bool
+	// Time the server spent on this stage; for EventExpired, the time
+	// since the challenge was sent
+	Latency time.Duration
+	// Why the stage failed, for failures
+	Err error
+}
+
+// Sends e to the observer, if there is one, as an event of kind that
+// started at start, and to the config's metrics.
+func (s *Server) observe(ctx context.Context, e Event, kind EventKind, start time.Time, err error) {
+	e.Kind = kind
+	e.Time = time.Now()
+	e.Latency = e.Time.Sub(start)
+	e.Err = err
+
+	s.measure(e)
+	if s.observer != nil {
+		s.observer.Observe(ctx, e)
+	}
+}
@@ -0,0 +1,82 @@
+package libgosrp
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServerEvents(t *testing.T) {
+	server, config := testserver(t)
+
+	var mu sync.Mutex
+	var events []Event
+	server.SetObserver(ObserverFunc(func(ctx context.Context, e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}))
+
+	//returns the kinds of the events since the last call
+	kinds := func() []EventKind {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var k []EventKind
+		for _, e := range events {
+			k = append(k, e.Kind)
+		}
+		events = nil
+		return k
+	}
+
+	testattempt(server, config, "192.0.2.1:1000", "alice", "password123")
+	if k := kinds(); !reflect.DeepEqual(k, []EventKind{EventChallenge, EventSuccess}) {
+		t.Errorf("Error: wrong events for a login: %v", k)
+	}
+
+	testattempt(server, config, "192.0.2.1:1000", "alice", "wrong")
+	if k := kinds(); !reflect.DeepEqual(k, []EventKind{EventChallenge, EventProofFailed}) {
+		t.Errorf("Error: wrong events for a failed login: %v", k)
+	}
+
+	testattempt(server, config, "192.0.2.1:1000", "nobody", "password123")
+	mu.Lock()
+	for _, e := range events {
+		if !e.UnknownUser || e.User != "nobody" || e.Addr != "192.0.2.1" || e.Profile != ProfileRFC5054 {
+			t.Errorf("Error: wrong details for an unknown user: %+v", e)
+		}
+	}
+	mu.Unlock()
+	if k := kinds(); !reflect.DeepEqual(k, []EventKind{EventUnknownUser, EventProofFailed}) {
+		t.Errorf("Error: wrong events for an unknown user: %v", k)
+	}
+
+	N := config.gp.N.Bytes()
+	if _, _, err := server.Hello(context.Background(), ClientHello{"alice", N}); err == nil {
+		t.Error("Error: A = N accepted.")
+	}
+	if k := kinds(); !reflect.DeepEqual(k, []EventKind{EventInvalidA}) {
+		t.Errorf("Error: wrong events for an invalid A: %v", k)
+	}
+
+	server.SetSessionTimeout(time.Millisecond)
+	csess, _ := new(SRPClientSession).New("alice", config)
+	hello, _ := csess.Hello()
+	id, challenge, err := server.Hello(context.Background(), hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	csess.ReadChallenge(challenge, "password123")
+	proof, _ := csess.Proof()
+	if _, err = server.Proof(context.Background(), id, proof); err == nil {
+		t.Error("Error: proof accepted after the session expired.")
+	}
+	if k := kinds(); !reflect.DeepEqual(k, []EventKind{EventChallenge, EventExpired}) {
+		t.Errorf("Error: wrong events for an expired session: %v", k)
+	}
+}
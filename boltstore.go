@@ -0,0 +1,206 @@
+package libgosrp
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets and keys of a BoltStore file.
+var (
+	boltVerifiers = []byte("verifiers")
+	boltMeta      = []byte("meta")
+	boltDataKey   = []byte("data-key")
+)
+
+// Associated data for the wrapped data key, so it can't be passed off
+// as a verifier record or the other way around.
+var boltKeyAD = []byte("libgosrp data key")
+
+// A VerifierStore in a bbolt database file, for programs that want to
+// keep their verifiers on local disk without a database server.
+//
+// Verifiers are encrypted at rest with AES-256-GCM, so a copy of the
+// file alone doesn't allow offline guessing. Each record is encrypted
+// under a data key, with the username as associated data, so records
+// can't be swapped between users without detection. The data key is
+// stored in the file encrypted under the master key the program
+// supplies, which RotateMasterKey can replace without rewriting the
+// records. Usernames are not encrypted. Safe for concurrent use.
+type BoltStore struct {
+	db   *bolt.DB
+	data cipher.AEAD
+}
+
+// Returned when the master key doesn't decrypt the data key, or a
+// record doesn't decrypt under the data key.
+type ErrorDecryption string
+
+func (e ErrorDecryption) Error() string {
+	return fmt.Sprintf("Could not decrypt %s: the key is wrong or the data was altered.", string(e))
+}
+
+// Opens the store in the file at path, creating it if needed, with a
+// 32 byte master key. A new file gets a random data key.
+func OpenBoltStore(path string, masterKey []byte) (*BoltStore, error) {
+	master, err := new_aead(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BoltStore{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltVerifiers); err != nil {
+			return err
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(boltMeta)
+		if err != nil {
+			return err
+		}
+
+		var key []byte
+		if wrapped := meta.Get(boltDataKey); wrapped != nil {
+			if key, err = aead_open(master, wrapped, boltKeyAD); err != nil {
+				return ErrorDecryption("the data key")
+			}
+		} else {
+			key = make([]byte, 32)
+			if _, err = rand.Read(key); err != nil {
+				return err
+			}
+
+			wrapped, err := aead_seal(master, key, boltKeyAD)
+			if err != nil {
+				return err
+			}
+
+			if err = meta.Put(boltDataKey, wrapped); err != nil {
+				return err
+			}
+		}
+		defer wipe_bytes(key)
+
+		b.data, err = new_aead(key)
+		return err
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *BoltStore) Get(ctx context.Context, i string) (Verifier, error) {
+	var v Verifier
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltVerifiers).Get([]byte(i))
+		if value == nil {
+			return ErrorUnknownUser(i)
+		}
+
+		plaintext, err := aead_open(b.data, value, []byte(i))
+		if err != nil {
+			return ErrorDecryption(fmt.Sprintf("the verifier for user %q", i))
+		}
+		defer wipe_bytes(plaintext)
+
+		return v.UnmarshalJSON(plaintext)
+	})
+
+	return v, err
+}
+
+func (b *BoltStore) Put(ctx context.Context, v Verifier) error {
+	if v.I == "" {
+		return new(EmptyUsernameError)
+	}
+
+	plaintext, err := v.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	defer wipe_bytes(plaintext)
+
+	value, err := aead_seal(b.data, plaintext, []byte(v.I))
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltVerifiers).Put([]byte(v.I), value)
+	})
+}
+
+func (b *BoltStore) Delete(ctx context.Context, i string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltVerifiers)
+		if bucket.Get([]byte(i)) == nil {
+			return ErrorUnknownUser(i)
+		}
+
+		return bucket.Delete([]byte(i))
+	})
+}
+
+func (b *BoltStore) List(ctx context.Context) ([]string, error) {
+	var names []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		//bolt keeps keys sorted by bytes
+		return tx.Bucket(boltVerifiers).ForEach(func(k, _ []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+
+	return names, err
+}
+
+// Encrypts the data key under newKey in place of oldKey, the current
+// master key. newKey must be used to open the store from then on. The
+// records are unchanged, so this is quick however many there are.
+func (b *BoltStore) RotateMasterKey(oldKey, newKey []byte) error {
+	oldMaster, err := new_aead(oldKey)
+	if err != nil {
+		return err
+	}
+
+	newMaster, err := new_aead(newKey)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMeta)
+
+		//checks oldKey is the current key, so a mistyped key can't
+		//lock everyone out
+		key, err := aead_open(oldMaster, meta.Get(boltDataKey), boltKeyAD)
+		if err != nil {
+			return ErrorDecryption("the data key")
+		}
+		defer wipe_bytes(key)
+
+		wrapped, err := aead_seal(newMaster, key, boltKeyAD)
+		if err != nil {
+			return err
+		}
+
+		return meta.Put(boltDataKey, wrapped)
+	})
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
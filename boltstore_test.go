@@ -0,0 +1,93 @@
+package libgosrp
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "verifiers.db")
+	config, _ := GetProfile(ProfileRFC5054)
+	key, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+
+	store, err := OpenBoltStore(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []string{"carol", "alice", "bob"} {
+		var v Verifier
+		if _, err = v.New(i, "password123", 16, config); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = store.Put(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = store.Delete(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.RotateMasterKey(newKey, key); err == nil {
+		t.Error("Error: rotated the master key without the current key.")
+	}
+
+	if err = store.RotateMasterKey(key, newKey); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	if _, err = OpenBoltStore(path, key); err == nil {
+		t.Fatal("Error: opened the store with the old master key.")
+	} else if _, ok := err.(ErrorDecryption); !ok {
+		t.Error("Error: wrong error for the old master key:", err)
+	}
+
+	//records can't be moved to another user
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltVerifiers)
+		if bytes.Contains(b.Get([]byte("alice")), []byte("alice")) {
+			t.Error("Error: record stored in the clear.")
+		}
+
+		return b.Put([]byte("mallory"), b.Get([]byte("alice")))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenBoltStore(path, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	names, _ := reopened.List(ctx)
+	if !reflect.DeepEqual(names, []string{"alice", "carol", "mallory"}) {
+		t.Errorf("Error: wrong users after reopening: %v", names)
+	}
+
+	if _, err = reopened.Get(ctx, "mallory"); err == nil {
+		t.Error("Error: record moved to another user was accepted.")
+	}
+
+	v, err := reopened.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testhandshake(t, v, "password123", config, config)
+}
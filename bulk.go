@@ -0,0 +1,209 @@
+package libgosrp
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// A user to make a verifier for in GenerateVerifiers.
+type BulkRecord struct {
+	I string
+	P string
+	//Set instead of P when migrating from a system that only kept
+	//hashes of passwords. The hash is used as the password, so
+	//clients must apply the same hash before starting a handshake.
+	PreHash []byte
+}
+
+func (r *BulkRecord) password() string {
+	if r.PreHash != nil {
+		return string(r.PreHash)
+	}
+
+	return r.P
+}
+
+type BulkOptions struct {
+	//Number of verifiers computed at once. Defaults to the number
+	//of CPUs.
+	Workers int
+	//Length of the salts generated. Defaults to 16.
+	SaltLen uint
+	//Called with the running totals every ProgressEvery records,
+	//and at the end if they have changed since. Calls are never
+	//concurrent.
+	Progress      func(BulkProgress)
+	ProgressEvery int
+	//Called for each record that fails. Without it, the first
+	//failure stops the run.
+	Failed func(r BulkRecord, err error)
+}
+
+// Stores that write several verifiers at once more cheaply than one
+// at a time, such as FileStore.
+type batchStore interface {
+	PutBatch(ctx context.Context, verifiers []Verifier) error
+}
+
+type BulkProgress struct {
+	Done   int //verifiers stored
+	Failed int
+}
+
+// Reads records until the channel is closed, computing their
+// verifiers with config across a pool of workers and storing them.
+// Returns the totals, and the first failure if Failed isn't set, or
+// ctx.Err() if ctx was cancelled first. Records still in the channel
+// when the run stops are left there. Stores with a PutBatch method,
+// such as FileStore, are written to once per progress report rather
+// than once per record; the verifiers of a batch not yet written when
+// the run stops are dropped.
+func GenerateVerifiers(ctx context.Context, config *SRPConfig, records <-chan BulkRecord, store VerifierStore, opts BulkOptions) (BulkProgress, error) {
+	if err := config.check_init(); err != nil {
+		return BulkProgress{}, err
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	if opts.SaltLen == 0 {
+		opts.SaltLen = 16
+	}
+
+	if opts.ProgressEvery <= 0 {
+		opts.ProgressEvery = 1000
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batch, _ := store.(batchStore)
+
+	type result struct {
+		record   BulkRecord
+		verifier Verifier
+		err      error
+	}
+
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				var r BulkRecord
+				var ok bool
+
+				select {
+				case <-ctx.Done():
+					return
+				case r, ok = <-records:
+					if !ok {
+						return
+					}
+				}
+
+				var v Verifier
+				var err error
+				if r.I == "" {
+					err = new(EmptyUsernameError)
+				} else if _, err = v.NewContext(ctx, r.I, r.password(), opts.SaltLen, config); err == nil && batch == nil {
+					err = store.Put(ctx, v)
+				}
+
+				//a record cut short by the run stopping isn't a
+				//failure
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+
+				select {
+				case results <- result{r, v, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var progress BulkProgress
+	var failure error
+
+	count := func(res result) {
+		if res.err == nil {
+			progress.Done++
+		} else {
+			progress.Failed++
+
+			if opts.Failed != nil {
+				opts.Failed(res.record, res.err)
+			} else if failure == nil {
+				failure = res.err
+				cancel()
+			}
+		}
+	}
+
+	//verifiers computed but not yet handed to batch
+	var pending []result
+	flush := func() {
+		if len(pending) == 0 || ctx.Err() != nil {
+			return
+		}
+
+		verifiers := make([]Verifier, len(pending))
+		for i := range pending {
+			verifiers[i] = pending[i].verifier
+		}
+
+		err := batch.PutBatch(ctx, verifiers)
+		for _, res := range pending {
+			res.err = err
+			count(res)
+		}
+		pending = pending[:0]
+	}
+
+	//the totals last reported, once there has been a report
+	var reported *BulkProgress
+	report := func() {
+		if opts.Progress != nil && (reported == nil || *reported != progress) {
+			last := progress
+			reported = &last
+			opts.Progress(progress)
+		}
+	}
+
+	seen := 0
+	for res := range results {
+		seen++
+		if batch != nil && res.err == nil {
+			pending = append(pending, res)
+		} else {
+			count(res)
+		}
+
+		if seen%opts.ProgressEvery == 0 {
+			flush()
+			report()
+		}
+	}
+	flush()
+	report()
+
+	if failure != nil {
+		return progress, failure
+	}
+
+	return progress, ctx.Err()
+}
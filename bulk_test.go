@@ -0,0 +1,137 @@
+package libgosrp
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func testrecords(n int) <-chan BulkRecord {
+	records := make(chan BulkRecord, n)
+	for j := 0; j < n; j++ {
+		records <- BulkRecord{I: fmt.Sprintf("user%d", j), P: fmt.Sprintf("password%d", j)}
+	}
+
+	close(records)
+	return records
+}
+
+func TestGenerateVerifiers(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+	store := NewMemoryStore()
+
+	var reports []BulkProgress
+	progress, err := GenerateVerifiers(context.Background(), config, testrecords(20), store, BulkOptions{
+		Workers:       4,
+		Progress:      func(p BulkProgress) { reports = append(reports, p) },
+		ProgressEvery: 5,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//no repeat of the last report at the end
+	if progress.Done != 20 || progress.Failed != 0 || len(reports) != 4 || reports[3] != progress {
+		t.Errorf("Error: wrong progress: %+v, reports %+v", progress, reports)
+	}
+
+	v, err := store.Get(context.Background(), "user7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testhandshake(t, v, "password7", config, config)
+
+	//a client migrated from SHA-1 password hashes
+	hash := sha1.Sum([]byte("secret"))
+	records := make(chan BulkRecord, 2)
+	records <- BulkRecord{I: "legacy", PreHash: hash[:]}
+	records <- BulkRecord{P: "no username"}
+	close(records)
+
+	var failed []BulkRecord
+	progress, err = GenerateVerifiers(context.Background(), config, records, store, BulkOptions{
+		Failed: func(r BulkRecord, err error) { failed = append(failed, r) },
+	})
+
+	if err != nil || progress.Done != 1 || len(failed) != 1 || failed[0].P != "no username" {
+		t.Errorf("Error: failures not reported: %v %+v %+v", err, progress, failed)
+	}
+
+	v, _ = store.Get(context.Background(), "legacy")
+	testhandshake(t, v, string(hash[:]), config, config)
+}
+
+// A FileStore that counts the batches written to it.
+type countingStore struct {
+	*FileStore
+	batches int
+}
+
+func (c *countingStore) PutBatch(ctx context.Context, verifiers []Verifier) error {
+	c.batches++
+	return c.FileStore.PutBatch(ctx, verifiers)
+}
+
+func TestGenerateVerifiersBatch(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+	path := filepath.Join(t.TempDir(), "verifiers.json")
+
+	file, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &countingStore{FileStore: file}
+
+	var reports []BulkProgress
+	progress, err := GenerateVerifiers(context.Background(), config, testrecords(12), store, BulkOptions{
+		Workers:       4,
+		Progress:      func(p BulkProgress) { reports = append(reports, p) },
+		ProgressEvery: 5,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//two full batches and the remainder
+	if progress.Done != 12 || store.batches != 3 || len(reports) != 3 || reports[0].Done != 5 {
+		t.Errorf("Error: %d batches, progress %+v, reports %+v", store.batches, progress, reports)
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names, _ := reopened.List(context.Background()); len(names) != 12 {
+		t.Errorf("Error: file holds %d verifiers.", len(names))
+	}
+
+	v, _ := reopened.Get(context.Background(), "user11")
+	testhandshake(t, v, "password11", config, config)
+}
+
+func TestGenerateVerifiersCancel(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	progress, err := GenerateVerifiers(ctx, config, testrecords(100), NewMemoryStore(), BulkOptions{})
+	if err != context.Canceled || progress.Done == 100 {
+		t.Errorf("Error: cancelled run returned %v after %d records.", err, progress.Done)
+	}
+
+	//without a Failed callback, the first failure stops the run
+	records := make(chan BulkRecord)
+	go func() {
+		records <- BulkRecord{P: "no username"}
+	}()
+
+	if _, err = GenerateVerifiers(context.Background(), config, records, NewMemoryStore(), BulkOptions{}); err == nil {
+		t.Error("Error: failure did not stop the run.")
+	}
+}
@@ -0,0 +1,250 @@
+package libgosrp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// CBOR major types used by the handshake messages.
+const (
+	cborUint  = 0
+	cborBytes = 2
+	cborText  = 3
+	cborArray = 4
+	cborMap   = 5
+)
+
+// Messages as CBOR (RFC 8949) maps from field name to value, with
+// versions as unsigned integers and lists as arrays of text, in the
+// deterministic encoding of RFC 8949 section 4.2: definite lengths,
+// shortest length arguments and keys sorted by their encoding.
+// Decoding is strict and rejects anything else, including unknown or
+// missing fields.
+type CBORCodec struct{}
+
+func (CBORCodec) Name() string {
+	return "cbor"
+}
+
+func (CBORCodec) Marshal(m interface{}) ([]byte, error) {
+	_, fields, err := messageFields(m)
+	if err != nil {
+		return nil, err
+	}
+
+	fields = cborSort(fields)
+
+	out := cborHead(nil, cborMap, uint64(len(fields)))
+	for _, f := range fields {
+		b, err := f.encode()
+		if err != nil {
+			return nil, err
+		}
+
+		out = cborHead(out, cborText, uint64(len(f.name)))
+		out = append(out, f.name...)
+
+		switch {
+		case f.version != nil:
+			out = cborHead(out, cborUint, uint64(b[0]))
+		case f.list != nil:
+			list, _ := readlist(b)
+			out = cborHead(out, cborArray, uint64(len(list)))
+			for _, s := range list {
+				out = cborHead(out, cborText, uint64(len(s)))
+				out = append(out, s...)
+			}
+		case f.text != nil:
+			out = cborHead(out, cborText, uint64(len(b)))
+			out = append(out, b...)
+		default:
+			out = cborHead(out, cborBytes, uint64(len(b)))
+			out = append(out, b...)
+		}
+	}
+
+	return out, nil
+}
+
+func (CBORCodec) Unmarshal(data []byte, m interface{}) error {
+	_, fields, err := messageFields(m)
+	if err != nil {
+		return err
+	}
+
+	fields = cborSort(fields)
+	values := make([][]byte, len(fields))
+
+	major, n, data, err := cborReadHead(data)
+	if err != nil {
+		return err
+	} else if major != cborMap || n != uint64(len(fields)) {
+		return ErrorMalformed(fmt.Sprintf("expected a CBOR map of %d fields", len(fields)))
+	}
+
+	//keys must appear in the same order as they're written
+	for i, f := range fields {
+		var key []byte
+		if major, key, data, err = cborReadString(data); err != nil {
+			return err
+		} else if major != cborText || string(key) != f.name {
+			return ErrorMalformed(fmt.Sprintf("expected CBOR key %q", fields[i].name))
+		}
+
+		switch {
+		case f.version != nil:
+			var n uint64
+			if major, n, data, err = cborReadHead(data); err != nil {
+				return err
+			} else if major != cborUint || n > 0xff {
+				return ErrorMalformed(fmt.Sprintf("%s must be a CBOR integer from 0 to 255", f.name))
+			}
+			values[i] = []byte{byte(n)}
+		case f.list != nil:
+			if values[i], data, err = cborReadList(data, f.name); err != nil {
+				return err
+			}
+		default:
+			expected := byte(cborBytes)
+			if f.text != nil {
+				expected = cborText
+			}
+
+			if major, values[i], data, err = cborReadString(data); err != nil {
+				return err
+			} else if major != expected {
+				return ErrorMalformed(fmt.Sprintf("wrong CBOR type for %s", f.name))
+			}
+		}
+	}
+
+	if len(data) > 0 {
+		return ErrorMalformed("trailing data")
+	}
+
+	return setFields(fields, values)
+}
+
+// Sorts fields by the encoding of their keys: shorter first, then
+// bytewise. Keys are short, so this is the same as sorting by length
+// then name.
+func cborSort(fields []messageField) []messageField {
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i].name, fields[j].name
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+
+		return a < b
+	})
+
+	return fields
+}
+
+func cborHead(out []byte, major byte, n uint64) []byte {
+	major <<= 5
+
+	switch {
+	case n < 24:
+		return append(out, major|byte(n))
+	case n <= 0xff:
+		return append(out, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(out, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(out, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(out, major|27), n)
+	}
+}
+
+// Reads the initial byte and length argument of a data item,
+// rejecting indefinite and non-minimal lengths.
+func cborReadHead(data []byte) (byte, uint64, []byte, error) {
+	if len(data) == 0 {
+		return 0, 0, nil, ErrorMalformed("truncated CBOR")
+	}
+
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	var n uint64
+	size := 0
+	switch {
+	case info < 24:
+		return major, uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, nil, ErrorMalformed("indefinite or reserved CBOR length")
+	}
+
+	if len(data) < size {
+		return 0, 0, nil, ErrorMalformed("truncated CBOR")
+	}
+
+	for _, b := range data[:size] {
+		n = n<<8 | uint64(b)
+	}
+
+	if len(cborHead(nil, major, n)) != 1+size {
+		return 0, 0, nil, ErrorMalformed("non-minimal CBOR length")
+	}
+
+	return major, n, data[size:], nil
+}
+
+// Reads a byte or text string of at most maxValueLen bytes.
+func cborReadString(data []byte) (byte, []byte, []byte, error) {
+	major, n, data, err := cborReadHead(data)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	if major != cborBytes && major != cborText {
+		return 0, nil, nil, ErrorMalformed("expected a CBOR string")
+	} else if n > maxValueLen {
+		return 0, nil, nil, ErrorMalformed(fmt.Sprintf("CBOR string exceeds the limit of %d bytes", maxValueLen))
+	} else if uint64(len(data)) < n {
+		return 0, nil, nil, ErrorMalformed("truncated CBOR")
+	}
+
+	return major, data[:n], data[n:], nil
+}
+
+// Reads an array of text strings into the binary value of the list
+// field name.
+func cborReadList(data []byte, name string) ([]byte, []byte, error) {
+	major, n, data, err := cborReadHead(data)
+	if err != nil {
+		return nil, nil, err
+	} else if major != cborArray {
+		return nil, nil, ErrorMalformed(fmt.Sprintf("%s must be a CBOR array", name))
+	} else if n > uint64(len(data)) {
+		return nil, nil, ErrorMalformed("truncated CBOR")
+	}
+
+	w := wireWriter{}
+	for ; n > 0; n-- {
+		var s []byte
+		if major, s, data, err = cborReadString(data); err != nil {
+			return nil, nil, err
+		} else if major != cborText || len(s) > maxStringLen {
+			return nil, nil, ErrorMalformed(fmt.Sprintf("%s must hold text of at most %d bytes", name, maxStringLen))
+		}
+
+		w.write(s)
+		if len(w) > maxValueLen {
+			return nil, nil, ErrorMalformed(fmt.Sprintf("%s must be at most %d bytes", name, maxValueLen))
+		}
+	}
+
+	return w, data, nil
+}
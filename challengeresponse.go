@@ -1,6 +0,0 @@
-package libgosrp
-
-type ChallengeResponse struct {
-	Salt string
-	B    string
-}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	libgosrp "github.com/japorito/go-srp"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Settings read from the JSON config file. Durations are strings such
+// as "30s".
+type Config struct {
+	//address of the HTTP API
+	Listen string `json:"listen"`
+	//address of the framed TCP protocol, if wanted
+	TCPListen string `json:"tcp_listen,omitempty"`
+	Profile   string `json:"profile"`
+	//registered group to use instead of the profile's own
+	Group string `json:"group,omitempty"`
+	//codec of the HTTP request and response bodies
+	Codec           string      `json:"codec"`
+	Store           StoreConfig `json:"store"`
+	SessionTimeout  Duration    `json:"session_timeout"`
+	MaxPending      int         `json:"max_pending"`
+	ShutdownTimeout Duration    `json:"shutdown_timeout"`
+	//accept new verifiers on /register
+	AllowRegistration bool `json:"allow_registration"`
+	//build a table of powers of g at startup
+	Precompute bool `json:"precompute"`
+	//slow down and lock out repeated failed logins, by user and by
+	//client address
+	Throttle bool `json:"throttle"`
+	//log every stage of every login
+	Audit bool `json:"audit"`
+	//serve Prometheus metrics on /metrics
+	Metrics bool `json:"metrics"`
+	//pepper keys: the first seals verifiers, the rest only open them
+	Pepper []PepperKey `json:"pepper,omitempty"`
+}
+
+type PepperKey struct {
+	ID string `json:"id"`
+	//file holding the key, in hex
+	KeyFile string `json:"key_file"`
+}
+
+type StoreConfig struct {
+	//"file", "bolt" or "sqlite"
+	Type string `json:"type"`
+	Path string `json:"path"`
+	//file holding the master key of a bolt store, in hex
+	KeyFile string `json:"key_file,omitempty"`
+}
+
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	var err error
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func defaultConfig() Config {
+	var c Config
+	c.Listen = "127.0.0.1:8080"
+	c.Profile = libgosrp.ProfileRFC5054
+	c.Codec = "json"
+	c.Store.Type = "file"
+	c.Store.Path = "verifiers.json"
+	c.SessionTimeout.Duration = libgosrp.DefaultSessionTimeout
+	c.MaxPending = libgosrp.DefaultMaxPending
+	c.ShutdownTimeout.Duration = 10 * time.Second
+	c.Throttle = true
+	return c
+}
+
+// Reads the config file at path over the defaults. Unknown settings
+// are errors, so typos don't go unnoticed.
+func loadConfig(path string) (Config, error) {
+	c := defaultConfig()
+	if path == "" {
+		return c, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return c, nil
+}
+
+// Returns the SRP config the settings describe, and its pepper if
+// there is one.
+func (c *Config) srpConfig() (*libgosrp.SRPConfig, *libgosrp.Pepper, error) {
+	config, err := libgosrp.GetProfile(c.Profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.Group != "" {
+		gp, err := libgosrp.LookupGroup(c.Group)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		config.SetGroup(gp)
+	}
+
+	config.SetPrecompute(c.Precompute)
+
+	var pepper *libgosrp.Pepper
+	for n, k := range c.Pepper {
+		key, err := readKey(k.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if n == 0 {
+			pepper, err = libgosrp.NewPepper(k.ID, key)
+		} else {
+			err = pepper.AddKey(k.ID, key)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	config.SetPepper(pepper)
+	return config, pepper, nil
+}
+
+// Reads a key written in hex to the file at path.
+func readKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return key, nil
+}
+
+func (c *Config) openStore() (libgosrp.VerifierStore, error) {
+	opener, ok := stores[c.Store.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown store type %q", c.Store.Type)
+	}
+
+	return opener(c.Store)
+}
+
+// Store types, by name in the config file.
+var stores = map[string]func(c StoreConfig) (libgosrp.VerifierStore, error){
+	"file": func(c StoreConfig) (libgosrp.VerifierStore, error) {
+		return libgosrp.OpenFileStore(c.Path)
+	},
+	"bolt": func(c StoreConfig) (libgosrp.VerifierStore, error) {
+		if c.KeyFile == "" {
+			return nil, errors.New("bolt store needs a key_file")
+		}
+
+		key, err := readKey(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return libgosrp.OpenBoltStore(c.Path, key)
+	},
+	"sqlite": func(c StoreConfig) (libgosrp.VerifierStore, error) {
+		db, err := sql.Open("sqlite3", c.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		store, err := libgosrp.OpenSQLStore(context.Background(), db, libgosrp.DialectSQLite)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return sqliteStore{store, db}, nil
+	},
+}
+
+// An SQLStore that closes its database along with itself.
+type sqliteStore struct {
+	*libgosrp.SQLStore
+	db *sql.DB
+}
+
+func (s sqliteStore) Close() error {
+	err := s.SQLStore.Close()
+	if dberr := s.db.Close(); err == nil {
+		err = dberr
+	}
+
+	return err
+}
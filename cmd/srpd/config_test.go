@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	libgosrp "github.com/japorito/go-srp"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	c := Config{Store: StoreConfig{Type: "sqlite", Path: filepath.Join(t.TempDir(), "verifiers.db")}}
+
+	store, err := c.openStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, _ := libgosrp.GetProfile(libgosrp.ProfileRFC5054)
+	var v libgosrp.Verifier
+	v.New("alice", "password123", 16, config)
+	if err = store.Put(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.(interface{ Close() error }).Close(); err != nil {
+		t.Error("Error: closing the store:", err)
+	}
+
+	//the records outlive the daemon
+	if store, err = c.openStore(); err != nil {
+		t.Fatal(err)
+	}
+	defer store.(interface{ Close() error }).Close()
+
+	if got, err := store.Get(ctx, "alice"); err != nil || got.Verifier.Cmp(&v.Verifier) != 0 {
+		t.Errorf("Error: reopened store gave %v.", err)
+	}
+}
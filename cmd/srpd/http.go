@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	libgosrp "github.com/japorito/go-srp"
+)
+
+// Header carrying the session id between the two login requests.
+const sessionHeader = "SRP-Session"
+
+// Largest request body accepted.
+const maxBody = 64 << 10
+
+var contentTypes = map[string]string{
+	"json":     "application/json",
+	"binary":   "application/octet-stream",
+	"cbor":     "application/cbor",
+	"protobuf": "application/x-protobuf",
+}
+
+// The HTTP API:
+//
+//	POST /login/hello  ClientHello -> ServerChallenge, with the session
+//	                   id in the SRP-Session response header
+//	POST /login/proof  ClientProof, with the SRP-Session header ->
+//	                   ServerProof
+//	POST /register     a verifier in its JSON encoding, if enabled; it
+//	                   is sealed with the pepper, if there is one
+//	GET  /healthz      200 while the daemon is serving
+//	GET  /metrics      metrics in the Prometheus text format, if
+//	                   enabled
+//
+// Message bodies use the configured codec. Failed requests get an
+// ErrorMessage.
+type api struct {
+	server   *libgosrp.Server
+	store    libgosrp.VerifierStore
+	config   *libgosrp.SRPConfig
+	pepper   *libgosrp.Pepper
+	codec    libgosrp.Codec
+	register bool
+	metrics  *libgosrp.PrometheusMetrics
+}
+
+func (a *api) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/hello", method(http.MethodPost, a.hello))
+	mux.HandleFunc("/login/proof", method(http.MethodPost, a.proof))
+	mux.HandleFunc("/register", method(http.MethodPost, a.registerVerifier))
+	mux.HandleFunc("/healthz", method(http.MethodGet, a.health))
+	if a.metrics != nil {
+		mux.HandleFunc("/metrics", method(http.MethodGet, a.metrics.ServeHTTP))
+	}
+	return mux
+}
+
+// Restricts h to requests using method m.
+func method(m string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			w.Header().Set("Allow", m)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func (a *api) hello(w http.ResponseWriter, r *http.Request) {
+	var hello libgosrp.ClientHello
+	if !a.read(w, r, &hello) {
+		return
+	}
+
+	id, challenge, err := a.server.Hello(libgosrp.WithRemoteAddr(r.Context(), r.RemoteAddr), hello)
+	if err != nil {
+		a.fail(w, err)
+		return
+	}
+
+	w.Header().Set(sessionHeader, id)
+	a.write(w, http.StatusOK, &challenge)
+}
+
+func (a *api) proof(w http.ResponseWriter, r *http.Request) {
+	var p libgosrp.ClientProof
+	if !a.read(w, r, &p) {
+		return
+	}
+
+	ctx := libgosrp.WithRemoteAddr(r.Context(), r.RemoteAddr)
+	login, err := a.server.Proof(ctx, r.Header.Get(sessionHeader), p)
+	if err != nil {
+		a.fail(w, err)
+		return
+	}
+
+	a.write(w, http.StatusOK, &login.Proof)
+}
+
+func (a *api) registerVerifier(w http.ResponseWriter, r *http.Request) {
+	if !a.register {
+		http.NotFound(w, r)
+		return
+	}
+
+	var v libgosrp.Verifier
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBody)).Decode(&v); err != nil {
+		a.fail(w, libgosrp.ErrorMalformed(err.Error()))
+		return
+	}
+
+	if err := v.Check(a.config); err != nil {
+		a.fail(w, libgosrp.ErrorMalformed(err.Error()))
+		return
+	} else if v.Pepper != "" {
+		a.fail(w, libgosrp.ErrorMalformed("clients can't seal verifiers"))
+		return
+	}
+
+	if a.pepper != nil {
+		if err := a.pepper.Seal(&v, a.config); err != nil {
+			a.fail(w, err)
+			return
+		}
+	}
+
+	//registration never replaces an existing user
+	if _, err := a.store.Get(r.Context(), v.I); err == nil {
+		a.write(w, http.StatusConflict, &libgosrp.ErrorMessage{Code: "user_exists", Message: "User already registered."})
+		return
+	}
+
+	if err := a.store.Put(r.Context(), v); err != nil {
+		a.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (a *api) health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"status":"ok"}`+"\n")
+}
+
+func (a *api) read(w http.ResponseWriter, r *http.Request, m interface{}) bool {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err == nil {
+		err = a.codec.Unmarshal(body, m)
+	}
+
+	if err != nil {
+		a.fail(w, libgosrp.ErrorMalformed(err.Error()))
+		return false
+	}
+
+	return true
+}
+
+func (a *api) write(w http.ResponseWriter, status int, m interface{}) {
+	body, err := a.codec.Marshal(m)
+	if err != nil {
+		log.Printf("encoding response: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[a.codec.Name()])
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (a *api) fail(w http.ResponseWriter, err error) {
+	e := errorMessage(err)
+
+	status := http.StatusBadRequest
+	switch e.Code {
+	case libgosrp.CodeBadProof:
+		status = http.StatusUnauthorized
+	case libgosrp.CodeBusy:
+		status = http.StatusServiceUnavailable
+	case libgosrp.CodeThrottled:
+		status = http.StatusTooManyRequests
+		if wait, ok := err.(libgosrp.ErrorThrottled); ok {
+			seconds := (time.Duration(wait) + time.Second - 1) / time.Second
+			w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+		}
+	case libgosrp.CodeInternal:
+		status = http.StatusInternalServerError
+	}
+
+	a.write(w, status, &e)
+}
+
+// Returns the message to send for err, keeping the details of
+// internal errors in the log.
+func errorMessage(err error) libgosrp.ErrorMessage {
+	e := libgosrp.NewErrorMessage(err)
+	if e.Code == libgosrp.CodeInternal {
+		log.Printf("internal error: %v", err)
+		e.Message = "Internal error."
+	}
+
+	return e
+}
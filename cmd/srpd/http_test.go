@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	libgosrp "github.com/japorito/go-srp"
+)
+
+func testapi(t *testing.T) (*api, *libgosrp.SRPConfig) {
+	config, _ := libgosrp.GetProfile(libgosrp.ProfileRFC5054)
+
+	store := libgosrp.NewMemoryStore()
+	var v libgosrp.Verifier
+	if _, err := v.New("alice", "password123", 16, config); err != nil {
+		t.Fatal(err)
+	}
+	store.Put(context.Background(), v)
+
+	server, err := libgosrp.NewServer(config, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	return &api{server: server, store: store, config: config, codec: libgosrp.JSONCodec{}, register: true}, config
+}
+
+// Posts m, encoded with codec, to url, decoding the response body into
+// reply. Returns the response.
+func testpost(t *testing.T, codec libgosrp.Codec, url, session string, m, reply interface{}) *http.Response {
+	body, err := codec.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if reply != nil {
+		if err = codec.Unmarshal(data, reply); err != nil {
+			t.Fatalf("Error: decoding %s response %q: %v", url, data, err)
+		}
+	}
+
+	return resp
+}
+
+// Logs in over the HTTP API, returning the status of the proof
+// request.
+func testhttplogin(t *testing.T, a *api, url string, config *libgosrp.SRPConfig, i, p string) int {
+	csess, err := new(libgosrp.SRPClientSession).New(i, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello, _ := csess.Hello()
+	var challenge libgosrp.ServerChallenge
+	resp := testpost(t, a.codec, url+"/login/hello", "", &hello, &challenge)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Error: hello gave status %d.", resp.StatusCode)
+	}
+
+	session := resp.Header.Get(sessionHeader)
+	if session == "" {
+		t.Fatal("Error: hello gave no session id.")
+	}
+
+	if err = csess.ReadChallenge(challenge, p); err != nil {
+		t.Fatal(err)
+	}
+
+	proof, _ := csess.Proof()
+	return testpost(t, a.codec, url+"/login/proof", session, &proof, nil).StatusCode
+}
+
+func TestHTTPLogin(t *testing.T) {
+	a, config := testapi(t)
+	ts := httptest.NewServer(a.handler())
+	defer ts.Close()
+
+	csess, _ := new(libgosrp.SRPClientSession).New("alice", config)
+	hello, _ := csess.Hello()
+
+	var challenge libgosrp.ServerChallenge
+	resp := testpost(t, a.codec, ts.URL+"/login/hello", "", &hello, &challenge)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Error: hello gave status %d.", resp.StatusCode)
+	}
+
+	if err := csess.ReadChallenge(challenge, "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	proof, _ := csess.Proof()
+	var reply libgosrp.ServerProof
+	resp = testpost(t, a.codec, ts.URL+"/login/proof", resp.Header.Get(sessionHeader), &proof, &reply)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Error: proof gave status %d.", resp.StatusCode)
+	}
+
+	if err := csess.ReadProof(reply); err != nil {
+		t.Error("Error: server proof rejected:", err)
+	}
+
+	if status := testhttplogin(t, a, ts.URL, config, "alice", "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("Error: wrong password gave status %d.", status)
+	}
+
+	//a proof for a session that was never started
+	var e libgosrp.ErrorMessage
+	resp = testpost(t, a.codec, ts.URL+"/login/proof", "nonsense", &proof, &e)
+	if resp.StatusCode == http.StatusOK || e.Code == "" {
+		t.Errorf("Error: unknown session gave status %d, code %q.", resp.StatusCode, e.Code)
+	}
+
+	resp, err := http.Get(ts.URL + "/login/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Error: GET of /login/hello gave status %d.", resp.StatusCode)
+	}
+}
+
+func TestHTTPRegister(t *testing.T) {
+	a, config := testapi(t)
+	ts := httptest.NewServer(a.handler())
+	defer ts.Close()
+
+	register := func(v libgosrp.Verifier) int {
+		body, _ := v.MarshalJSON()
+		resp, err := http.Post(ts.URL+"/register", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	var v libgosrp.Verifier
+	v.New("bob", "hunter2", 16, config)
+	if status := register(v); status != http.StatusCreated {
+		t.Fatalf("Error: registration gave status %d.", status)
+	}
+
+	if status := testhttplogin(t, a, ts.URL, config, "bob", "hunter2"); status != http.StatusOK {
+		t.Errorf("Error: login of a registered user gave status %d.", status)
+	}
+
+	if status := register(v); status != http.StatusConflict {
+		t.Errorf("Error: registering bob again gave status %d.", status)
+	}
+
+	//verifiers for another profile are refused
+	other, _ := libgosrp.GetProfile(libgosrp.ProfileRFC2945)
+	v.New("carol", "hunter2", 16, other)
+	if status := register(v); status != http.StatusBadRequest {
+		t.Errorf("Error: verifier for another profile gave status %d.", status)
+	}
+
+	a.register = false
+	v.New("dave", "hunter2", 16, config)
+	if status := register(v); status != http.StatusNotFound {
+		t.Errorf("Error: registration while disabled gave status %d.", status)
+	}
+}
+
+func TestHTTPHealth(t *testing.T) {
+	a, _ := testapi(t)
+	ts := httptest.NewServer(a.handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !bytes.Contains(body, []byte(`"ok"`)) {
+		t.Errorf("Error: /healthz gave status %d, body %q.", resp.StatusCode, body)
+	}
+
+	resp, err = http.Post(ts.URL+"/healthz", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Error: POST of /healthz gave status %d.", resp.StatusCode)
+	}
+}
@@ -0,0 +1,160 @@
+// Command srpd serves SRP registration and login over HTTP, and
+// optionally over a framed TCP protocol, for services that want SRP
+// authentication as a sidecar.
+//
+// Usage:
+//
+//	srpd [-config srpd.json]
+//
+// See Config for the settings of the config file, api for the HTTP
+// endpoints and tcpServer for the TCP protocol.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	libgosrp "github.com/japorito/go-srp"
+)
+
+func main() {
+	path := flag.String("config", "", "JSON config file")
+	flag.Parse()
+
+	config, err := loadConfig(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = run(config); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Serves until SIGINT or SIGTERM, then stops accepting logins and
+// waits up to the shutdown timeout for those in progress.
+func run(config Config) error {
+	srp, pepper, err := config.srpConfig()
+	if err != nil {
+		return err
+	}
+
+	codec, err := libgosrp.GetCodec(config.Codec)
+	if err != nil {
+		return err
+	}
+
+	store, err := config.openStore()
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := store.(interface{ Close() error }); ok {
+		defer closer.Close()
+	}
+
+	var metrics *libgosrp.PrometheusMetrics
+	if config.Metrics {
+		metrics = libgosrp.NewPrometheusMetrics()
+		srp.SetMetrics(metrics)
+	}
+
+	server, err := libgosrp.NewServer(srp, store)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	server.SetSessionTimeout(config.SessionTimeout.Duration)
+	server.SetMaxPending(config.MaxPending)
+
+	if config.Throttle {
+		throttle := libgosrp.NewThrottle(libgosrp.NewMemoryCounter())
+		throttle.OnLockout = func(ctx context.Context, l libgosrp.Lockout) {
+			if l.User != "" {
+				log.Printf("user %q locked out until %v after %d failures", l.User, l.Until, l.Failures)
+			} else {
+				log.Printf("address %s locked out until %v after %d failures", l.Addr, l.Until, l.Failures)
+			}
+		}
+		server.SetThrottle(throttle)
+	}
+
+	if config.Audit {
+		server.SetObserver(libgosrp.ObserverFunc(logEvent))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a := &api{server, store, srp, pepper, codec, config.AllowRegistration, metrics}
+	httpServer := &http.Server{Addr: config.Listen, Handler: a.handler()}
+
+	errs := make(chan error, 2)
+	go func() {
+		log.Printf("serving HTTP on %s", config.Listen)
+		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}()
+
+	var tcp *tcpServer
+	var listener net.Listener
+	if config.TCPListen != "" {
+		if listener, err = net.Listen("tcp", config.TCPListen); err != nil {
+			return err
+		}
+
+		tcp = newTCPServer(server, config.SessionTimeout.Duration)
+		go func() {
+			log.Printf("serving TCP on %s", config.TCPListen)
+			if err := tcp.serve(listener); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Print("shutting down")
+	case err = <-errs:
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout.Duration)
+	defer cancel()
+
+	if serr := httpServer.Shutdown(shutdown); serr != nil && err == nil {
+		err = serr
+	}
+
+	if tcp != nil {
+		listener.Close()
+		tcp.shutdown(shutdown)
+	}
+
+	return err
+}
+
+// Logs e as key=value pairs.
+func logEvent(ctx context.Context, e libgosrp.Event) {
+	msg := fmt.Sprintf("audit event=%s user=%q addr=%s profile=%s group=%s latency=%v",
+		e.Kind, e.User, e.Addr, e.Profile, e.Group, e.Latency.Round(time.Microsecond))
+	if e.UnknownUser {
+		msg += " unknown_user=true"
+	}
+
+	if e.Err != nil {
+		msg += fmt.Sprintf(" err=%q", e.Err)
+	}
+
+	log.Print(msg)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	libgosrp "github.com/japorito/go-srp"
+)
+
+// The framed TCP protocol: each message is a big-endian uint32 length
+// followed by the message in the binary codec, whose first byte tells
+// the message types apart. A connection carries one login: the
+// client sends ClientHello and ClientProof, the server answers with
+// ServerChallenge and ServerProof, or an ErrorMessage and closes.
+type tcpServer struct {
+	server  *libgosrp.Server
+	timeout time.Duration
+
+	wg sync.WaitGroup
+	//guards conns and closed, and orders wg.Add before shutdown's
+	//wg.Wait
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+func newTCPServer(server *libgosrp.Server, timeout time.Duration) *tcpServer {
+	return &tcpServer{server: server, timeout: timeout, conns: make(map[net.Conn]struct{})}
+}
+
+// Largest frame accepted.
+const maxFrame = 4096
+
+func (t *tcpServer) serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		//connections accepted once shutdown has begun are dropped
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		t.conns[conn] = struct{}{}
+		t.wg.Add(1)
+		t.mu.Unlock()
+
+		go func() {
+			defer t.wg.Done()
+			t.login(conn)
+
+			t.mu.Lock()
+			delete(t.conns, conn)
+			t.mu.Unlock()
+		}()
+	}
+}
+
+// Waits for logins in progress to finish, closing their connections
+// if ctx ends first.
+func (t *tcpServer) shutdown(ctx context.Context) {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.mu.Lock()
+		for conn := range t.conns {
+			conn.Close()
+		}
+		t.mu.Unlock()
+		<-done
+	}
+}
+
+func (t *tcpServer) login(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.timeout))
+
+	ctx := libgosrp.WithRemoteAddr(context.Background(), conn.RemoteAddr().String())
+	codec := libgosrp.BinaryCodec{}
+
+	var hello libgosrp.ClientHello
+	if err := readFrame(conn, codec, &hello); err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	id, challenge, err := t.server.Hello(ctx, hello)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	if err = writeFrame(conn, codec, &challenge); err != nil {
+		return
+	}
+
+	var p libgosrp.ClientProof
+	if err = readFrame(conn, codec, &p); err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	login, err := t.server.Proof(ctx, id, p)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	writeFrame(conn, codec, &login.Proof)
+}
+
+func readFrame(r io.Reader, codec libgosrp.Codec, m interface{}) error {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return err
+	}
+
+	n := binary.BigEndian.Uint32(length[:])
+	if n > maxFrame {
+		return libgosrp.ErrorMalformed("frame too long")
+	}
+
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return err
+	}
+
+	return codec.Unmarshal(frame, m)
+}
+
+func writeFrame(w io.Writer, codec libgosrp.Codec, m interface{}) error {
+	body, err := codec.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	_, err = w.Write(append(frame, body...))
+	return err
+}
+
+func writeError(conn net.Conn, err error) {
+	var netErr net.Error
+	if errors.Is(err, io.EOF) || errors.As(err, &netErr) {
+		return
+	}
+
+	e := errorMessage(err)
+	if werr := writeFrame(conn, libgosrp.BinaryCodec{}, &e); werr != nil {
+		log.Printf("writing error to %v: %v", conn.RemoteAddr(), werr)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	libgosrp "github.com/japorito/go-srp"
+)
+
+func TestFrame(t *testing.T) {
+	codec := libgosrp.BinaryCodec{}
+	hello := libgosrp.ClientHello{I: "alice", A: []byte{1, 2, 3}}
+
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, codec, &hello); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := binary.BigEndian.Uint32(buf.Bytes()); int(n) != buf.Len()-4 {
+		t.Errorf("Error: frame length %d for a %d byte body.", n, buf.Len()-4)
+	}
+
+	var decoded libgosrp.ClientHello
+	if err := readFrame(&buf, codec, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.I != hello.I || !bytes.Equal(decoded.A, hello.A) {
+		t.Errorf("Error: frame decoded to %+v.", decoded)
+	}
+
+	//oversized frames are refused before the body is read
+	buf.Reset()
+	buf.Write(binary.BigEndian.AppendUint32(nil, maxFrame+1))
+	if err := readFrame(&buf, codec, &decoded); err == nil {
+		t.Error("Error: oversized frame accepted.")
+	} else if _, ok := err.(libgosrp.ErrorMalformed); !ok {
+		t.Error("Error: wrong error for an oversized frame:", err)
+	}
+}
+
+func TestTCPLogin(t *testing.T) {
+	a, config := testapi(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcp := newTCPServer(a.server, 10*time.Second)
+	served := make(chan error, 1)
+	go func() { served <- tcp.serve(l) }()
+
+	login := func(p string) (libgosrp.ServerProof, *libgosrp.SRPClientSession, error) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		codec := libgosrp.BinaryCodec{}
+		csess, _ := new(libgosrp.SRPClientSession).New("alice", config)
+		hello, _ := csess.Hello()
+		if err = writeFrame(conn, codec, &hello); err != nil {
+			t.Fatal(err)
+		}
+
+		var challenge libgosrp.ServerChallenge
+		if err = readFrame(conn, codec, &challenge); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = csess.ReadChallenge(challenge, p); err != nil {
+			t.Fatal(err)
+		}
+
+		proof, _ := csess.Proof()
+		if err = writeFrame(conn, codec, &proof); err != nil {
+			t.Fatal(err)
+		}
+
+		//a failed login is answered with an ErrorMessage, which the
+		//ServerProof decoder refuses
+		var reply libgosrp.ServerProof
+		return reply, csess, readFrame(conn, codec, &reply)
+	}
+
+	reply, csess, err := login("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = csess.ReadProof(reply); err != nil {
+		t.Error("Error: server proof rejected:", err)
+	}
+
+	if _, _, err = login("wrong"); err == nil {
+		t.Error("Error: wrong password accepted.")
+	}
+
+	l.Close()
+	if err = <-served; err != nil {
+		t.Error("Error: serve after the listener closed:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tcp.shutdown(ctx)
+}
@@ -0,0 +1,548 @@
+// Command srptool creates verifiers, manages verifier files, prints
+// groups and runs test handshakes, for provisioning and debugging
+// without writing Go.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"code.google.com/p/go.crypto/ssh/terminal"
+	libgosrp "github.com/japorito/go-srp"
+)
+
+const usage = `usage: srptool <command> [flags]
+
+commands:
+  verifier   print a new verifier for a user
+  add        add or replace a user in a verifier file
+  remove     remove a user from a verifier file
+  list       list the users in a verifier file
+  migrate    add users from a CSV file to a verifier file
+  group      print a group, or generate a new one
+  handshake  run a client against a server locally, printing each message
+  diff       find where two transcripts of a handshake first differ
+
+profiles: rfc5054, rfc2945, wow, python-srp, nimbus, thinbus
+
+Passwords are read from the terminal without echo, or a line at a time
+from standard input when it isn't a terminal. Run srptool <command> -h
+for the flags of each command.
+`
+
+type command func(args []string) error
+
+var commands = map[string]command{
+	"verifier":  verifierCommand,
+	"add":       addCommand,
+	"remove":    removeCommand,
+	"list":      listCommand,
+	"migrate":   migrateCommand,
+	"group":     groupCommand,
+	"handshake": handshakeCommand,
+	"diff":      diffCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "srptool:", err)
+		os.Exit(1)
+	}
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// Reads a password, asking twice on a terminal if confirm is set.
+func readPassword(prompt string, confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Again: ")
+		again, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+
+		if string(again) != string(p) {
+			return "", errors.New("passwords do not match")
+		}
+	}
+
+	return string(p), nil
+}
+
+// Prompts for the password of user i and returns their new verifier.
+func newVerifier(i, profile string, slen uint) (libgosrp.Verifier, error) {
+	var v libgosrp.Verifier
+
+	if i == "" {
+		return v, errors.New("-user is required")
+	}
+
+	config, err := libgosrp.GetProfile(profile)
+	if err != nil {
+		return v, err
+	}
+
+	p, err := readPassword(fmt.Sprintf("Password for %s: ", i), true)
+	if err != nil {
+		return v, err
+	}
+
+	_, err = v.New(i, p, slen, config)
+	return v, err
+}
+
+func verifierCommand(args []string) error {
+	flags := flag.NewFlagSet("verifier", flag.ExitOnError)
+	user := flags.String("user", "", "username")
+	profile := flags.String("profile", libgosrp.ProfileRFC5054, "profile to compute the verifier with")
+	slen := flags.Uint("salt", 16, "salt length in bytes")
+	format := flags.String("format", "json", "output format: json or text")
+	flags.Parse(args)
+
+	v, err := newVerifier(*user, *profile, *slen)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	switch *format {
+	case "json":
+		out, err = v.MarshalJSON()
+	case "text":
+		out, err = v.MarshalText()
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+func addCommand(args []string) error {
+	flags := flag.NewFlagSet("add", flag.ExitOnError)
+	file := flags.String("file", "verifiers.json", "verifier file")
+	user := flags.String("user", "", "username")
+	profile := flags.String("profile", libgosrp.ProfileRFC5054, "profile to compute the verifier with")
+	slen := flags.Uint("salt", 16, "salt length in bytes")
+	flags.Parse(args)
+
+	store, err := libgosrp.OpenFileStore(*file)
+	if err != nil {
+		return err
+	}
+
+	v, err := newVerifier(*user, *profile, *slen)
+	if err != nil {
+		return err
+	}
+
+	return store.Put(context.Background(), v)
+}
+
+func removeCommand(args []string) error {
+	flags := flag.NewFlagSet("remove", flag.ExitOnError)
+	file := flags.String("file", "verifiers.json", "verifier file")
+	user := flags.String("user", "", "username")
+	flags.Parse(args)
+
+	store, err := libgosrp.OpenFileStore(*file)
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(context.Background(), *user)
+}
+
+func listCommand(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	file := flags.String("file", "verifiers.json", "verifier file")
+	flags.Parse(args)
+
+	store, err := libgosrp.OpenFileStore(*file)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	names, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range names {
+		v, err := store.Get(ctx, i)
+		if err != nil {
+			return err
+		}
+
+		//the group's name, where the fingerprint is of a known group
+		group := v.Group
+		if name, _, err := libgosrp.LookupGroupByFingerprint(v.Group); err == nil {
+			group = name
+		}
+
+		fmt.Printf("%s\t%s\t%s\t%s\n", i, v.Profile, group, v.Created.Format("2006-01-02 15:04:05"))
+	}
+
+	return nil
+}
+
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	file := flags.String("file", "verifiers.json", "verifier file")
+	input := flags.String("csv", "", "CSV file of username,password records")
+	prehash := flags.Bool("prehash", false, "the second column holds hex encoded password hashes, not passwords")
+	profile := flags.String("profile", libgosrp.ProfileRFC5054, "profile to compute the verifiers with")
+	workers := flags.Int("workers", 0, "verifiers computed at once (default: number of CPUs)")
+	flags.Parse(args)
+
+	config, err := libgosrp.GetProfile(*profile)
+	if err != nil {
+		return err
+	}
+	config.SetPrecompute(true)
+
+	store, err := libgosrp.OpenFileStore(*file)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(*input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	records := make(chan libgosrp.BulkRecord)
+	readerr := make(chan error, 1)
+	go func() {
+		defer close(records)
+		readerr <- readRecords(ctx, csv.NewReader(in), *prehash, records)
+	}()
+
+	progress, err := libgosrp.GenerateVerifiers(ctx, config, records, store, libgosrp.BulkOptions{
+		Workers: *workers,
+		Progress: func(p libgosrp.BulkProgress) {
+			fmt.Fprintf(os.Stderr, "%d stored, %d failed\n", p.Done, p.Failed)
+		},
+		Failed: func(r libgosrp.BulkRecord, err error) {
+			fmt.Fprintf(os.Stderr, "%q: %v\n", r.I, err)
+		},
+	})
+	cancel()
+
+	if err == nil {
+		err = <-readerr
+	}
+
+	if err == nil && progress.Failed > 0 {
+		err = fmt.Errorf("%d records failed", progress.Failed)
+	}
+
+	return err
+}
+
+func readRecords(ctx context.Context, r *csv.Reader, prehash bool, records chan<- libgosrp.BulkRecord) error {
+	r.FieldsPerRecord = 2
+
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		record := libgosrp.BulkRecord{I: fields[0], P: fields[1]}
+		if prehash {
+			record.P = ""
+			if record.PreHash, err = hex.DecodeString(fields[1]); err != nil {
+				return fmt.Errorf("hash for %q: %v", fields[0], err)
+			}
+		}
+
+		select {
+		case records <- record:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func groupCommand(args []string) error {
+	flags := flag.NewFlagSet("group", flag.ExitOnError)
+	bits := flags.Int("bits", 2048, "size of N")
+	name := flags.String("name", "", "registered group to print, instead of the RFC 5054 group of -bits")
+	generate := flags.Bool("generate", false, "generate a new group of -bits")
+	list := flags.Bool("list", false, "list the registered groups")
+	format := flags.String("format", "pem", "output format: pem or json")
+	flags.Parse(args)
+
+	if *list {
+		for _, n := range libgosrp.GroupNames() {
+			gp, _ := libgosrp.LookupGroup(n)
+			fmt.Printf("%s\t%d\t%s\n", n, gp.N.BitLen(), gp.Fingerprint())
+		}
+
+		return nil
+	}
+
+	var gp libgosrp.SRPGroupParameters
+	var err error
+
+	switch {
+	case *generate:
+		fmt.Fprintf(os.Stderr, "Generating a %d-bit safe prime. This may take a while.\n", *bits)
+		gp, err = libgosrp.GenerateGroupParameters(*bits)
+	case *name != "":
+		gp, err = libgosrp.LookupGroup(*name)
+	default:
+		gp, err = libgosrp.GetGroupParameters(*bits)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	switch *format {
+	case "pem":
+		out, err = gp.MarshalPEM()
+	case "json":
+		out, err = gp.MarshalJSON()
+		out = append(out, '\n')
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.Write(out)
+	return nil
+}
+
+func handshakeCommand(args []string) error {
+	flags := flag.NewFlagSet("handshake", flag.ExitOnError)
+	user := flags.String("user", "alice", "username")
+	profile := flags.String("profile", libgosrp.ProfileRFC5054, "profile, when not using a verifier file")
+	file := flags.String("file", "", "verifier file holding the user, instead of making a new verifier")
+	transcripts := flags.String("transcript", "", "write the transcripts of both sides to `prefix`-client.json and prefix-server.json")
+	redact := flags.Bool("redact", false, "leave secrets, including the password, out of the transcripts")
+	flags.Parse(args)
+
+	var v libgosrp.Verifier
+	var err error
+
+	prompt := "Password to log in with: "
+	if *file != "" {
+		v, err = storedVerifier(*file, *user)
+		prompt = fmt.Sprintf("Password for %s: ", *user)
+	} else {
+		v, err = newVerifier(*user, *profile, 16)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	p, err := readPassword(prompt, false)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := v.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	fmt.Println("verifier:", string(verifier))
+
+	var ct, st libgosrp.Transcript
+	err = handshake(v, p, &ct, &st)
+
+	if *transcripts != "" {
+		for role, t := range map[string]*libgosrp.Transcript{"client": &ct, "server": &st} {
+			if *redact {
+				t.Redact()
+			}
+
+			data, merr := json.MarshalIndent(t, "", "    ")
+			if merr == nil {
+				merr = os.WriteFile(*transcripts+"-"+role+".json", append(data, '\n'), 0600)
+			}
+
+			if merr != nil && err == nil {
+				err = merr
+			}
+		}
+	}
+
+	return err
+}
+
+func storedVerifier(file, i string) (libgosrp.Verifier, error) {
+	store, err := libgosrp.OpenFileStore(file)
+	if err != nil {
+		return libgosrp.Verifier{}, err
+	}
+
+	return store.Get(context.Background(), i)
+}
+
+// Logs in as v.I with p against a server holding v, printing each
+// message and recording the transcripts of each side.
+func handshake(v libgosrp.Verifier, p string, ct, st *libgosrp.Transcript) error {
+	server, err := v.Config()
+	if err != nil {
+		return err
+	}
+
+	client, err := v.Config()
+	if err != nil {
+		return err
+	}
+
+	csess, ssess := new(libgosrp.SRPClientSession), new(libgosrp.SRPSession)
+	csess.SetTranscript(ct)
+	ssess.SetTranscript(st)
+
+	if _, err = csess.New(v.I, client); err != nil {
+		return err
+	}
+	defer csess.Close()
+
+	if _, err = ssess.New(v, server); err != nil {
+		return err
+	}
+	defer ssess.Close()
+
+	ca := libgosrp.ClientAdapter{Session: csess, Codec: libgosrp.JSONCodec{}}
+	sa := libgosrp.ServerAdapter{Session: ssess, Codec: libgosrp.JSONCodec{}}
+
+	hello, err := ca.Hello()
+	if err != nil {
+		return err
+	}
+	fmt.Println("client -> server:", string(hello))
+
+	if err = sa.ReadHello(hello); err != nil {
+		return fmt.Errorf("server rejected hello: %v", err)
+	}
+
+	challenge, err := sa.Challenge()
+	if err != nil {
+		return err
+	}
+	fmt.Println("server -> client:", string(challenge))
+
+	if err = ca.ReadChallenge(challenge, p); err != nil {
+		return fmt.Errorf("client rejected challenge: %v", err)
+	}
+
+	proof, err := ca.Proof()
+	if err != nil {
+		return err
+	}
+	fmt.Println("client -> server:", string(proof))
+
+	if err = sa.ReadProof(proof); err != nil {
+		return fmt.Errorf("server rejected proof: %v", err)
+	}
+
+	sproof, err := sa.Proof()
+	if err != nil {
+		return err
+	}
+	fmt.Println("server -> client:", string(sproof))
+
+	if err = ca.ReadProof(sproof); err != nil {
+		return fmt.Errorf("client rejected proof: %v", err)
+	}
+
+	fmt.Printf("session key: %X\n", csess.SessionKey())
+	fmt.Println("handshake succeeded")
+	return nil
+}
+
+func diffCommand(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: srptool diff ours theirs")
+		fmt.Fprintln(os.Stderr, "\nours is a transcript written by srptool handshake -transcript or")
+		fmt.Fprintln(os.Stderr, "SetTranscript; theirs may also be lines of name = hex value, as")
+		fmt.Fprintln(os.Stderr, "printed by another implementation.")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var transcripts [2]*libgosrp.Transcript
+	for j := range transcripts {
+		data, err := os.ReadFile(flags.Arg(j))
+		if err != nil {
+			return err
+		}
+
+		if transcripts[j], err = libgosrp.ParseTranscript(data); err != nil {
+			return fmt.Errorf("%s: %v", flags.Arg(j), err)
+		}
+	}
+
+	m := transcripts[0].Compare(transcripts[1])
+	if m == nil {
+		fmt.Println("no differences in the values both transcripts hold")
+		return nil
+	}
+
+	fmt.Println(m)
+	return errors.New("transcripts differ")
+}
@@ -0,0 +1,218 @@
+package libgosrp
+
+import (
+	"fmt"
+)
+
+// Encodes and decodes the handshake messages: *ClientHello,
+// *ServerChallenge, *ClientProof, *ServerProof and *ErrorMessage, and
+// the negotiation messages *Offer and *Selection.
+type Codec interface {
+	Name() string
+	Marshal(m interface{}) ([]byte, error)
+	Unmarshal(data []byte, m interface{}) error
+}
+
+type ErrNoCodecAvailable string
+
+func (e ErrNoCodecAvailable) Error() string {
+	return fmt.Sprintf("No codec named %q defined by this package!", string(e))
+}
+
+// Returns the codec with the given name: "json", "binary", "cbor" or
+// "protobuf".
+func GetCodec(name string) (Codec, error) {
+	switch name {
+	case "json":
+		return JSONCodec{}, nil
+	case "binary":
+		return BinaryCodec{}, nil
+	case "cbor":
+		return CBORCodec{}, nil
+	case "protobuf":
+		return ProtobufCodec{}, nil
+	default:
+		return nil, ErrNoCodecAvailable(name)
+	}
+}
+
+// One field of a handshake message: text, bytes, a list of strings
+// or a version number. Each codec writes the field in its own way, but
+// they all check and set it through its binary value: the bytes, the
+// text, one byte for a version, or each string of a list with a
+// uint16 length.
+type messageField struct {
+	name    string
+	number  int //protobuf field number
+	text    *string
+	bytes   *[]byte
+	list    *[]string
+	version *int
+	//bytes that may be left empty
+	optional bool
+}
+
+// Returns the binary record kind and the fields of a message.
+func messageFields(m interface{}) (byte, []messageField, error) {
+	switch m := m.(type) {
+	case *ClientHello:
+		return kindClientHello, []messageField{{name: "I", number: 1, text: &m.I}, {name: "A", number: 2, bytes: &m.A}}, nil
+	case *ServerChallenge:
+		return kindServerChallenge, []messageField{{name: "Salt", number: 1, bytes: &m.Salt}, {name: "B", number: 2, bytes: &m.B}}, nil
+	case *ClientProof:
+		return kindClientProof, []messageField{{name: "M1", number: 1, bytes: &m.M1}}, nil
+	case *ServerProof:
+		return kindServerProof, []messageField{{name: "M2", number: 1, bytes: &m.M2}}, nil
+	case *ErrorMessage:
+		return kindError, []messageField{{name: "Code", number: 1, text: &m.Code}, {name: "Message", number: 2, text: &m.Message}}, nil
+	case *Offer:
+		return kindOffer, []messageField{
+			{name: "Version", number: 1, version: &m.Version},
+			{name: "I", number: 2, text: &m.I},
+			{name: "Profiles", number: 3, list: &m.Profiles},
+		}, nil
+	case *Selection:
+		return kindSelection, []messageField{
+			{name: "Version", number: 1, version: &m.Version},
+			{name: "Profile", number: 2, text: &m.Profile},
+			{name: "N", number: 3, bytes: &m.N, optional: true},
+			{name: "G", number: 4, bytes: &m.G, optional: true},
+		}, nil
+	default:
+		return 0, nil, ErrorMalformed(fmt.Sprintf("%T is not a handshake message", m))
+	}
+}
+
+// Longest value allowed in the field.
+func (f *messageField) limit() int {
+	switch {
+	case f.text != nil:
+		return maxStringLen
+	case f.version != nil:
+		return 1
+	default:
+		return maxValueLen
+	}
+}
+
+// Returns the field's binary value, checking it's within limits.
+func (f *messageField) encode() ([]byte, error) {
+	var b []byte
+	switch {
+	case f.text != nil:
+		b = []byte(*f.text)
+	case f.version != nil:
+		if *f.version < 0 || *f.version > 0xff {
+			return nil, ErrorMalformed(fmt.Sprintf("%s must be 0 to 255", f.name))
+		}
+		b = []byte{byte(*f.version)}
+	case f.list != nil:
+		w := wireWriter{}
+		for _, s := range *f.list {
+			if err := checktext(f.name, s); err != nil {
+				return nil, err
+			}
+			w.write([]byte(s))
+		}
+		b = w
+	default:
+		b = *f.bytes
+	}
+
+	return b, f.check(b)
+}
+
+func (f *messageField) check(b []byte) error {
+	switch {
+	case f.text != nil:
+		return checktext(f.name, string(b))
+	case f.version != nil:
+		if len(b) != 1 {
+			return ErrorMalformed(fmt.Sprintf("%s must be 1 byte", f.name))
+		}
+	case f.list != nil:
+		if len(b) > maxValueLen {
+			return ErrorMalformed(fmt.Sprintf("%s must be at most %d bytes", f.name, maxValueLen))
+		}
+
+		_, err := readlist(b)
+		return err
+	case f.optional && len(b) == 0:
+	case len(b) == 0 || len(b) > maxValueLen:
+		return ErrorMalformed(fmt.Sprintf("%s must be 1 to %d bytes", f.name, maxValueLen))
+	}
+
+	return nil
+}
+
+// Sets the field to a copy of b, which must have passed check().
+func (f *messageField) set(b []byte) {
+	switch {
+	case f.text != nil:
+		*f.text = string(b)
+	case f.version != nil:
+		*f.version = int(b[0])
+	case f.list != nil:
+		*f.list, _ = readlist(b)
+	default:
+		*f.bytes = append([]byte(nil), b...)
+	}
+}
+
+// Returns the strings in the binary value of a list field.
+func readlist(b []byte) ([]string, error) {
+	var list []string
+
+	r := wireReader{b: b}
+	for r.err == nil && len(r.b) > 0 {
+		list = append(list, r.text())
+	}
+
+	return list, r.close()
+}
+
+// Checks each value and sets the fields only if all are valid.
+func setFields(fields []messageField, values [][]byte) error {
+	for i := range fields {
+		if err := fields[i].check(values[i]); err != nil {
+			return err
+		}
+	}
+
+	for i := range fields {
+		fields[i].set(values[i])
+	}
+
+	return nil
+}
+
+// Messages as JSON objects; see MarshalJSON on each message.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+func (JSONCodec) Marshal(m interface{}) ([]byte, error) {
+	return marshalMessageJSON(m)
+}
+
+func (JSONCodec) Unmarshal(data []byte, m interface{}) error {
+	return unmarshalMessageJSON(data, m)
+}
+
+// Messages in this package's length-prefixed binary form; see
+// MarshalBinary on each message.
+type BinaryCodec struct{}
+
+func (BinaryCodec) Name() string {
+	return "binary"
+}
+
+func (BinaryCodec) Marshal(m interface{}) ([]byte, error) {
+	return marshalMessage(m)
+}
+
+func (BinaryCodec) Unmarshal(data []byte, m interface{}) error {
+	return unmarshalMessage(data, m)
+}
@@ -0,0 +1,126 @@
+package libgosrp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func testhex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+func testmessages() []interface{} {
+	return []interface{}{
+		&ClientHello{"alice", []byte{0x00, 0xAB}},
+		&ServerChallenge{testhex("BEB25379D1A8581EB5A727673A2441EE"), []byte{0x01, 0x02}},
+		&ClientProof{testhex("3F3BC67169EA71302599CF1B0F5D408B7B65D347")},
+		&ServerProof{testhex("9CAB3C575A11DE37D3AC1421A9F009236A48EB55")},
+		&ErrorMessage{CodeBadProof, "Authentication failed: client proof did not match."},
+		&ErrorMessage{CodeInternal, ""},
+		&Offer{ProtocolVersion, "alice", []string{ProfileNimbus, ProfileRFC5054}},
+		&Offer{ProtocolVersion, "alice", nil},
+		&Selection{ProtocolVersion, ProfileRFC5054, nil, nil},
+		&Selection{ProtocolVersion, ProfileRFC5054, testhex("EEAF0AB9ADB38DD69C33F80AFA8FC5E8"), []byte{0x02}},
+	}
+}
+
+// Every message must survive a round trip through every codec, and no
+// codec may accept trailing or truncated data.
+func TestCodecConformance(t *testing.T) {
+	for _, name := range []string{"json", "binary", "cbor", "protobuf"} {
+		codec, err := GetCodec(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range testmessages() {
+			data, err := codec.Marshal(m)
+			if err != nil {
+				t.Errorf("Error: %s could not encode %T: %v", name, m, err)
+				continue
+			}
+
+			decoded := reflect.New(reflect.TypeOf(m).Elem()).Interface()
+			if err = codec.Unmarshal(data, decoded); err != nil {
+				t.Errorf("Error: %s could not decode %T: %v", name, m, err)
+			} else if !reflect.DeepEqual(decoded, m) {
+				t.Errorf("Error: %T changed after %s round trip.\nExpected: %+v\nGot: %+v", m, name, m, decoded)
+			}
+
+			if len(data) > 1 {
+				if err = codec.Unmarshal(data[:len(data)-1], decoded); err == nil {
+					t.Errorf("Error: %s accepted truncated %T.", name, m)
+				}
+			}
+		}
+
+		if _, err = codec.Marshal(&ClientProof{}); err == nil {
+			t.Errorf("Error: %s encoded a malformed proof.", name)
+		}
+
+		if _, err = codec.Marshal(new(Verifier)); err == nil {
+			t.Errorf("Error: %s encoded a type that is not a handshake message.", name)
+		}
+	}
+}
+
+func TestCodecVectors(t *testing.T) {
+	p := &ClientProof{[]byte{0x01, 0x02}}
+
+	vectors := map[string][]byte{
+		"cbor":     {0xA1, 0x62, 'M', '1', 0x42, 0x01, 0x02},
+		"protobuf": {0x0A, 0x02, 0x01, 0x02},
+		"json":     []byte(`{"M1":"0102"}`),
+	}
+
+	for name, expected := range vectors {
+		codec, _ := GetCodec(name)
+		data, err := codec.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Error: %s encoding incorrect.\nExpected: %X\nGot: %X", name, expected, data)
+		}
+	}
+
+	//non-minimal length
+	var decoded ClientProof
+	if err := (CBORCodec{}).Unmarshal([]byte{0xA1, 0x62, 'M', '1', 0x58, 0x02, 0x01, 0x02}, &decoded); err == nil {
+		t.Error("Error: CBOR decoder accepted a non-minimal length.")
+	}
+
+	//unknown fields are skipped
+	if err := (ProtobufCodec{}).Unmarshal([]byte{0x10, 0x05, 0x0A, 0x02, 0x01, 0x02}, &decoded); err != nil || !bytes.Equal(decoded.M1, []byte{0x01, 0x02}) {
+		t.Errorf("Error: protobuf decoder did not skip an unknown field: %v", err)
+	}
+
+	//JSON objects must have exactly the message's fields
+	for _, data := range []string{`{}`, `{"M1": "0102", "M2": "0102"}`, `{"M1": null}`, `{"M1": ""}`} {
+		if err := (JSONCodec{}).Unmarshal([]byte(data), &decoded); err == nil {
+			t.Errorf("Error: JSON decoder accepted %s", data)
+		}
+	}
+
+	//versions are numbers, lists arrays and empty optional fields are
+	//left out
+	offer := Offer{1, "alice", []string{"rfc5054"}}
+	if data, _ := (JSONCodec{}).Marshal(&offer); string(data) != `{"Version":1,"I":"alice","Profiles":["rfc5054"]}` {
+		t.Errorf("Error: JSON encoding of an offer incorrect: %s", data)
+	}
+
+	selection := Selection{Version: 1, Profile: "rfc5054"}
+	if data, _ := (JSONCodec{}).Marshal(&selection); string(data) != `{"Version":1,"Profile":"rfc5054"}` {
+		t.Errorf("Error: JSON encoding of a selection incorrect: %s", data)
+	}
+
+	for _, data := range []string{`{"Version": 256, "Profile": "rfc5054"}`, `{"Version": "1", "Profile": "rfc5054"}`, `{"Version": 1, "Profile": "rfc5054", "N": null}`} {
+		if err := (JSONCodec{}).Unmarshal([]byte(data), &selection); err == nil {
+			t.Errorf("Error: JSON decoder accepted %s", data)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package libgosrp
+
+import (
+	"crypto/subtle"
+	"math/big"
+	"math/bits"
+	"time"
+)
+
+// Bits of the exponent consumed per multiplication.
+const expWindow = 4
+
+// Length in bytes of the private ephemeral values a and b.
+const ephemeralLen = 64
+
+// Montgomery arithmetic modulo an odd N, in words of big.Word size.
+// Every operation takes the same time whatever the values involved,
+// for use with secret bases and exponents. N itself is public.
+// Apart from t, a montgomery is never modified once made, so one can
+// be shared by taking a scratch() copy for each computation.
+type montgomery struct {
+	n     []uint //N, least significant word first
+	n0inv uint   //-N^-1 mod 2^W
+	rr    []uint //R^2 mod N, with R = 2^(W*len(n))
+	t     []uint //scratch space for mul
+}
+
+func newMontgomery(N *big.Int) *montgomery {
+	m := new(montgomery)
+	m.n = words(N, len(N.Bits()))
+
+	//Newton's iteration doubles the correct low bits each round
+	inv := uint(1)
+	for i := 0; i < 7; i++ {
+		inv *= 2 - m.n[0]*inv
+	}
+	m.n0inv = -inv
+
+	var rr big.Int
+	rr.Lsh(big.NewInt(1), uint(2*len(m.n)*bits.UintSize))
+	rr.Mod(&rr, N)
+	m.rr = words(&rr, len(m.n))
+
+	return m.scratch()
+}
+
+// Returns a copy of m with its own scratch space.
+func (m *montgomery) scratch() *montgomery {
+	c := *m
+	c.t = make([]uint, len(m.n)+2)
+	return &c
+}
+
+// Converts x from Montgomery form into a big.Int, wiping x.
+func (m *montgomery) result(x []uint) big.Int {
+	one := make([]uint, len(m.n))
+	one[0] = 1
+	m.mul(x, x, one)
+
+	z := make([]big.Word, len(x))
+	for i := range x {
+		z[i] = big.Word(x[i])
+	}
+
+	wipe_words(x)
+	wipe_words(m.t)
+
+	var result big.Int
+	result.SetBits(z)
+	return result
+}
+
+// Copies entry k of table, where entries are n words long, into
+// entry. Every entry is read, so k isn't revealed by which one was
+// accessed.
+func lookup(entry, table []uint, k uint) {
+	n := len(entry)
+	for j := range entry {
+		entry[j] = 0
+	}
+
+	for i := 0; i < len(table)/n; i++ {
+		mask := -uint(subtle.ConstantTimeEq(int32(i), int32(k)))
+		for j := range entry {
+			entry[j] |= table[i*n+j] & mask
+		}
+	}
+}
+
+// Returns window i of e, counting expWindow bit windows from the least
+// significant.
+func window(e []uint, i int) uint {
+	per := bits.UintSize / expWindow
+	return e[i/per] >> uint(i%per*expWindow) & (1<<expWindow - 1)
+}
+
+// Returns the words of x, zero extended to width.
+func words(x *big.Int, width int) []uint {
+	w := make([]uint, width)
+	for i, b := range x.Bits() {
+		w[i] = uint(b)
+	}
+
+	return w
+}
+
+// z = x*y/R mod N, by word-by-word (CIOS) Montgomery multiplication.
+// z may alias x or y.
+func (m *montgomery) mul(z, x, y []uint) {
+	n := len(m.n)
+	t := m.t
+	for j := range t {
+		t[j] = 0
+	}
+
+	for i := 0; i < n; i++ {
+		//t += x*y[i]
+		var c uint
+		for j := 0; j < n; j++ {
+			hi, lo := bits.Mul(x[j], y[i])
+			var cc uint
+			lo, cc = bits.Add(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		t[n], c = bits.Add(t[n], c, 0)
+		t[n+1] = c
+
+		//t = (t + q*N) / 2^W, where q makes the low word vanish
+		q := t[0] * m.n0inv
+		hi, lo := bits.Mul(q, m.n[0])
+		_, cc := bits.Add(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < n; j++ {
+			hi, lo = bits.Mul(q, m.n[j])
+			lo, cc = bits.Add(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[n-1], cc = bits.Add(t[n], c, 0)
+		t[n] = t[n+1] + cc
+	}
+
+	//t < 2N, so subtract N once, keeping the result only if it
+	//didn't borrow
+	var b uint
+	for j := 0; j < n; j++ {
+		z[j], b = bits.Sub(t[j], m.n[j], b)
+	}
+	_, b = bits.Sub(t[n], 0, b)
+
+	keep := -b
+	for j := 0; j < n; j++ {
+		z[j] = t[j]&keep | z[j]&^keep
+	}
+}
+
+// Returns the number of words an exponent is handled as: enough for
+// bound, a public bound on its size in bits, or for e itself if that
+// is wider.
+func exp_width(e *big.Int, bound int) int {
+	width := (bound + bits.UintSize - 1) / bits.UintSize
+	if len(e.Bits()) > width {
+		width = len(e.Bits())
+	}
+
+	if width == 0 {
+		width = 1
+	}
+
+	return width
+}
+
+// Returns base^e mod N. bound is a public bound on the size of e in
+// bits; the time taken depends only on the size of N and on bound, or
+// on the size of e if it is wider. base must be less than N.
+func (m *montgomery) exp(base, e *big.Int, bound int) big.Int {
+	m = m.scratch()
+	n := len(m.n)
+
+	one := make([]uint, n)
+	one[0] = 1
+
+	//entry i of table is base^i, in Montgomery form
+	table := make([]uint, n<<expWindow)
+	m.mul(table[:n], one, m.rr)
+	bw := words(base, n)
+	m.mul(table[n:2*n], bw, m.rr)
+	wipe_words(bw)
+	for i := 2; i < 1<<expWindow; i++ {
+		m.mul(table[i*n:(i+1)*n], table[(i-1)*n:i*n], table[n:2*n])
+	}
+
+	width := exp_width(e, bound)
+	ew := words(e, width)
+
+	acc := make([]uint, n)
+	copy(acc, table[:n])
+	entry := make([]uint, n)
+
+	for i := width*bits.UintSize/expWindow - 1; i >= 0; i-- {
+		for s := 0; s < expWindow; s++ {
+			m.mul(acc, acc, acc)
+		}
+
+		lookup(entry, table, window(ew, i))
+		m.mul(acc, acc, entry)
+	}
+
+	for _, w := range [][]uint{entry, ew, table} {
+		wipe_words(w)
+	}
+
+	return m.result(acc)
+}
+
+// Powers of a fixed base g, so that g^e needs one multiplication per
+// window of e and no squarings. Entry d of row i is g^(d*16^i), in
+// Montgomery form. Exponents may be as wide as N.
+type fixedBase struct {
+	m     *montgomery
+	table []uint
+}
+
+func newFixedBase(m *montgomery, g *big.Int) *fixedBase {
+	m = m.scratch()
+	n := len(m.n)
+	rows := n * bits.UintSize / expWindow
+	f := &fixedBase{m, make([]uint, rows*n<<expWindow)}
+
+	one := make([]uint, n)
+	one[0] = 1
+
+	//g^(16^i), in Montgomery form
+	power := make([]uint, n)
+	m.mul(power, words(g, n), m.rr)
+
+	for i := 0; i < rows; i++ {
+		row := f.table[i*n<<expWindow : (i+1)*n<<expWindow]
+		m.mul(row[:n], one, m.rr)
+		copy(row[n:2*n], power)
+		for d := 2; d < 1<<expWindow; d++ {
+			m.mul(row[d*n:(d+1)*n], row[(d-1)*n:d*n], power)
+		}
+
+		for s := 0; s < expWindow; s++ {
+			m.mul(power, power, power)
+		}
+	}
+
+	return f
+}
+
+// Returns g^e mod N, in time that depends only on the size of N and
+// on bound, a public bound on the size of e in bits, as for
+// montgomery.exp. Only the rows for that many bits are used. e must
+// be no wider than N.
+func (f *fixedBase) exp(e *big.Int, bound int) big.Int {
+	m := f.m.scratch()
+	n := len(m.n)
+
+	width := exp_width(e, bound)
+	if width > n {
+		width = n
+	}
+
+	ew := words(e, width)
+	acc := make([]uint, n)
+	entry := make([]uint, n)
+
+	stride := n << expWindow
+	for i := 0; i < width*bits.UintSize/expWindow; i++ {
+		lookup(entry, f.table[i*stride:(i+1)*stride], window(ew, i))
+		if i == 0 {
+			copy(acc, entry)
+		} else {
+			m.mul(acc, acc, entry)
+		}
+	}
+
+	wipe_words(entry)
+	wipe_words(ew)
+
+	return m.result(acc)
+}
+
+// base^e mod N, for use when either base or e is secret. bound is a
+// public bound on the size of e in bits, such as 8*ephemeralLen for a
+// and b. Falls back to math/big for even moduli, which no safe prime
+// group has.
+func (c *SRPConfig) exp_secret(base, e *big.Int, bound int) big.Int {
+	defer c.measure_exp(time.Now())
+	N := &c.gp.N
+
+	var result big.Int
+	if N.Bit(0) == 0 {
+		result.Exp(base, e, N)
+		return result
+	}
+
+	if base.Sign() < 0 || base.Cmp(N) >= 0 {
+		base = new(big.Int).Mod(base, N)
+		defer wipe(base)
+	}
+
+	return c.montgomery().exp(base, e, bound)
+}
+
+// g^e mod N for a secret e, using the fixed-base table if the config
+// has one. bound is as for exp_secret.
+func (c *SRPConfig) exp_g(e *big.Int, bound int) big.Int {
+	if c.precompute && c.gp.N.Bit(0) == 1 && len(e.Bits()) <= len(c.gp.N.Bits()) {
+		defer c.measure_exp(time.Now())
+		return c.fixed_base().exp(e, bound)
+	}
+
+	return c.exp_secret(&c.gp.G, e, bound)
+}
@@ -0,0 +1,133 @@
+package libgosrp
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestExpSecret(t *testing.T) {
+	for _, name := range []string{"rfc5054-1024", "rfc5054-2048", "wow"} {
+		gp, err := LookupGroup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config := new(SRPConfig).New(gp, H, RandomBytes)
+		config.SetPrecompute(true)
+		N := &gp.N
+		nminus1 := new(big.Int).Sub(N, big.NewInt(1))
+
+		bases := []*big.Int{big.NewInt(0), big.NewInt(1), &gp.G, nminus1}
+		exponents := []*big.Int{big.NewInt(0), big.NewInt(1), nminus1, new(big.Int).Lsh(N, 64)}
+
+		for i := 0; i < 8; i++ {
+			base, _ := rand.Int(rand.Reader, N)
+			e, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 512))
+			bases = append(bases, base)
+			exponents = append(exponents, e)
+		}
+
+		for _, base := range bases {
+			for _, e := range exponents {
+				expected := new(big.Int).Exp(base, e, N)
+				got := config.exp_secret(base, e, 512)
+
+				if expected.Cmp(&got) != 0 {
+					t.Errorf("Error: %s: %X^%X incorrect.\nExpected: %X\nGot: %X", name, base, e, expected, &got)
+				}
+			}
+		}
+
+		for _, e := range exponents {
+			expected := new(big.Int).Exp(&gp.G, e, N)
+			got := config.exp_g(e, 512)
+
+			if expected.Cmp(&got) != 0 {
+				t.Errorf("Error: %s: fixed-base g^%X incorrect.\nExpected: %X\nGot: %X", name, e, expected, &got)
+			}
+		}
+	}
+}
+
+// Cached values must follow the config's group.
+func TestConfigCache(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+	config.SetPrecompute(true)
+
+	for _, name := range []string{"rfc5054-2048", "rfc5054-1024", "rfc5054-2048"} {
+		gp, _ := LookupGroup(name)
+		config.gp = gp
+
+		fresh := new(SRPConfig).New(gp, H, RandomBytes)
+		fresh.SetHash(config.hash)
+
+		k, expected := config.calculate_k(), fresh.calculate_k()
+		if k.Cmp(&expected) != 0 {
+			t.Errorf("Error: stale k for %s.", name)
+		}
+
+		x := big.NewInt(12345)
+		gx := config.exp_g(x, 64)
+		if gx.Cmp(new(big.Int).Exp(&gp.G, x, &gp.N)) != 0 {
+			t.Errorf("Error: stale table of powers of g for %s.", name)
+		}
+	}
+}
+
+// Times g^e for a 64 byte e, as used for A and B, by each method.
+func benchmarkExp(b *testing.B, group, method string) {
+	gp, _ := LookupGroup(group)
+	config := new(SRPConfig).New(gp, H, RandomBytes)
+	config.SetPrecompute(method == "fixed")
+	e, _ := RandomBytes(64)
+	config.exp_g(&e, 8*ephemeralLen)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		switch method {
+		case "big":
+			new(big.Int).Exp(&gp.G, &e, &gp.N)
+		case "secret":
+			config.exp_secret(&gp.G, &e, 8*ephemeralLen)
+		case "fixed":
+			config.exp_g(&e, 8*ephemeralLen)
+		}
+	}
+}
+
+func BenchmarkExpMathBig(b *testing.B) {
+	benchmarkExp(b, "rfc5054-2048", "big")
+}
+
+func BenchmarkExpSecret(b *testing.B) {
+	benchmarkExp(b, "rfc5054-2048", "secret")
+}
+
+func BenchmarkExpFixedBase(b *testing.B) {
+	benchmarkExp(b, "rfc5054-2048", "fixed")
+}
+
+func BenchmarkExpMathBig3072(b *testing.B) {
+	benchmarkExp(b, "rfc5054-3072", "big")
+}
+
+func BenchmarkExpSecret3072(b *testing.B) {
+	benchmarkExp(b, "rfc5054-3072", "secret")
+}
+
+func BenchmarkExpFixedBase3072(b *testing.B) {
+	benchmarkExp(b, "rfc5054-3072", "fixed")
+}
+
+func BenchmarkExpMathBig4096(b *testing.B) {
+	benchmarkExp(b, "rfc5054-4096", "big")
+}
+
+func BenchmarkExpSecret4096(b *testing.B) {
+	benchmarkExp(b, "rfc5054-4096", "secret")
+}
+
+func BenchmarkExpFixedBase4096(b *testing.B) {
+	benchmarkExp(b, "rfc5054-4096", "fixed")
+}
@@ -0,0 +1,664 @@
+package libgosrp
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+	"unicode/utf8"
+)
+
+// Binary encodings start with one of these bytes, identifying the
+// record. Every field after it is a big-endian uint16 length followed
+// by that many bytes. Verifier numbers are big-endian without leading
+// zeros; message values are kept exactly as sent, and a list is its
+// strings, each written the same way.
+const (
+	kindVerifier byte = iota + 1
+	kindClientHello
+	kindServerChallenge
+	kindClientProof
+	kindServerProof
+	kindError
+	kindOffer
+	kindSelection
+)
+
+// Limits enforced when decoding.
+const (
+	// An 8192-bit number
+	maxValueLen = 1024
+	// Usernames, group and profile names
+	maxStringLen = 255
+)
+
+type ErrorMalformed string
+
+func (e ErrorMalformed) Error() string {
+	return fmt.Sprintf("Malformed SRP record: %s.", string(e))
+}
+
+type wireWriter []byte
+
+func (w *wireWriter) write(b []byte) {
+	*w = binary.BigEndian.AppendUint16(*w, uint16(len(b)))
+	*w = append(*w, b...)
+}
+
+type wireReader struct {
+	b   []byte
+	err error
+}
+
+func (r *wireReader) kind(expected byte) {
+	if len(r.b) == 0 || r.b[0] != expected {
+		r.fail("wrong record type")
+		return
+	}
+
+	r.b = r.b[1:]
+}
+
+func (r *wireReader) read(max int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if len(r.b) < 2 {
+		r.fail("truncated")
+		return nil
+	}
+
+	n := int(binary.BigEndian.Uint16(r.b))
+	if n > max {
+		r.fail(fmt.Sprintf("%d byte field exceeds the limit of %d", n, max))
+		return nil
+	} else if len(r.b) < 2+n {
+		r.fail("truncated")
+		return nil
+	}
+
+	field := r.b[2 : 2+n]
+	r.b = r.b[2+n:]
+	return field
+}
+
+func (r *wireReader) text() string {
+	t := r.read(maxStringLen)
+	if !utf8.Valid(t) {
+		r.fail("string is not valid UTF-8")
+	}
+
+	return string(t)
+}
+
+func (r *wireReader) number() big.Int {
+	var n big.Int
+
+	b := r.read(maxValueLen)
+	if r.err == nil && (len(b) == 0 || b[0] == 0) {
+		r.fail("number is empty or has leading zeros")
+	}
+
+	n.SetBytes(b)
+	return n
+}
+
+func (r *wireReader) close() error {
+	if r.err == nil && len(r.b) > 0 {
+		r.fail("trailing data")
+	}
+
+	return r.err
+}
+
+func (r *wireReader) fail(reason string) {
+	if r.err == nil {
+		r.err = ErrorMalformed(reason)
+	}
+}
+
+// Checks a hex encoded message value and returns its bytes.
+func hexvalue(name, value string) ([]byte, error) {
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, ErrorMalformed(fmt.Sprintf("%s is not valid hex", name))
+	} else if len(b) == 0 || len(b) > maxValueLen {
+		return nil, ErrorMalformed(fmt.Sprintf("%s must be 1 to %d bytes", name, maxValueLen))
+	}
+
+	return b, nil
+}
+
+func checktext(name, value string) error {
+	if len(value) > maxStringLen || !utf8.ValidString(value) {
+		return ErrorMalformed(fmt.Sprintf("%s must be valid UTF-8 of at most %d bytes", name, maxStringLen))
+	}
+
+	return nil
+}
+
+func marshaltext(b []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	text := make([]byte, base64.RawURLEncoding.EncodedLen(len(b)))
+	base64.RawURLEncoding.Encode(text, b)
+	return text, nil
+}
+
+func unmarshaltext(text []byte) ([]byte, error) {
+	b := make([]byte, base64.RawURLEncoding.DecodedLen(len(text)))
+	if _, err := base64.RawURLEncoding.Strict().Decode(b, text); err != nil {
+		return nil, ErrorMalformed("text form is not unpadded base64url")
+	}
+
+	return b, nil
+}
+
+// Encodes the verifier as: version, I, salt, verifier, group,
+// profile, creation time in seconds since the Unix epoch, and the
+// pepper id, which is left out when empty.
+func (v *Verifier) MarshalBinary() ([]byte, error) {
+	if err := v.check_record(); err != nil {
+		return nil, err
+	}
+
+	w := wireWriter{kindVerifier, byte(v.Version)}
+	w.write([]byte(v.I))
+	w.write(v.Salt.Bytes())
+	w.write(v.Verifier.Bytes())
+	w.write([]byte(v.Group))
+	w.write([]byte(v.Profile))
+
+	var created []byte
+	if !v.Created.IsZero() {
+		created = binary.BigEndian.AppendUint64(nil, uint64(v.Created.Unix()))
+	}
+	w.write(created)
+
+	if v.Pepper != "" {
+		w.write([]byte(v.Pepper))
+	}
+
+	return w, nil
+}
+
+func (v *Verifier) UnmarshalBinary(data []byte) error {
+	r := wireReader{b: data}
+	r.kind(kindVerifier)
+
+	var record Verifier
+	if r.err == nil && len(r.b) > 0 {
+		record.Version = int(r.b[0])
+		r.b = r.b[1:]
+	}
+
+	record.I = r.text()
+	record.Salt = r.number()
+	record.Verifier = r.number()
+	record.Group = r.text()
+	record.Profile = r.text()
+
+	if created := r.read(8); r.err == nil {
+		if len(created) == 8 {
+			record.Created = time.Unix(int64(binary.BigEndian.Uint64(created)), 0).UTC()
+		} else if len(created) != 0 {
+			r.fail("creation time must be 8 bytes")
+		}
+	}
+
+	if r.err == nil && len(r.b) > 0 {
+		record.Pepper = r.text()
+	}
+
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	if err := record.check_record(); err != nil {
+		return err
+	}
+
+	*v = record
+	return nil
+}
+
+// The text form is the binary form in unpadded base64url.
+func (v *Verifier) MarshalText() ([]byte, error) {
+	return marshaltext(v.MarshalBinary())
+}
+
+func (v *Verifier) UnmarshalText(text []byte) error {
+	b, err := unmarshaltext(text)
+	if err != nil {
+		return err
+	}
+
+	return v.UnmarshalBinary(b)
+}
+
+type verifierJSON struct {
+	Version  int       `json:"version"`
+	I        string    `json:"I"`
+	Salt     string    `json:"salt"`
+	Verifier string    `json:"verifier"`
+	Group    string    `json:"group,omitempty"`
+	Profile  string    `json:"profile,omitempty"`
+	Created  time.Time `json:"created"`
+	Pepper   string    `json:"pepper,omitempty"`
+}
+
+// Salt and verifier are upper case hex. The verifier is padded to the
+// length of N when the group is registered.
+func (v Verifier) MarshalJSON() ([]byte, error) {
+	if err := v.check_record(); err != nil {
+		return nil, err
+	}
+
+	verifier := v.Verifier.Bytes()
+	if width := v.width(); width > len(verifier) {
+		verifier = Pad(width, verifier)
+	}
+
+	return json.Marshal(verifierJSON{
+		Version:  v.Version,
+		I:        v.I,
+		Salt:     fmt.Sprintf("%X", v.Salt.Bytes()),
+		Verifier: fmt.Sprintf("%X", verifier),
+		Group:    v.Group,
+		Profile:  v.Profile,
+		Created:  v.Created,
+		Pepper:   v.Pepper,
+	})
+}
+
+func (v *Verifier) UnmarshalJSON(data []byte) error {
+	var j verifierJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	record := Verifier{I: j.I, Group: j.Group, Profile: j.Profile, Created: j.Created, Version: j.Version, Pepper: j.Pepper}
+
+	salt, err := hexvalue("salt", j.Salt)
+	if err != nil {
+		return err
+	} else if salt[0] == 0 {
+		return ErrorMalformed("salt has leading zeros")
+	}
+	record.Salt.SetBytes(salt)
+
+	verifier, err := hexvalue("verifier", j.Verifier)
+	if err != nil {
+		return err
+	}
+	record.Verifier.SetBytes(verifier)
+
+	if width := record.width(); width > 0 && len(verifier) != width {
+		return ErrorMalformed(fmt.Sprintf("verifier must be %d bytes for group %s", width, record.Group))
+	} else if width == 0 && verifier[0] == 0 {
+		return ErrorMalformed("verifier has leading zeros")
+	}
+
+	if err = record.check_record(); err != nil {
+		return err
+	}
+
+	*v = record
+	return nil
+}
+
+// Length of N for the verifier's group, or 0 if it isn't registered.
+func (v *Verifier) width() int {
+	gp, err := LookupGroup(v.Group)
+	if err != nil {
+		return 0
+	}
+
+	return len(gp.N.Bytes())
+}
+
+func (v *Verifier) check_record() error {
+	if v.Version < 0 || v.Version > VerifierVersion {
+		return ErrorMalformed(fmt.Sprintf("unsupported verifier version %d", v.Version))
+	}
+
+	if v.I == "" {
+		return ErrorMalformed("empty username")
+	}
+
+	for name, value := range map[string]string{"username": v.I, "group": v.Group, "profile": v.Profile, "pepper": v.Pepper} {
+		if err := checktext(name, value); err != nil {
+			return err
+		}
+	}
+
+	for name, n := range map[string]*big.Int{"salt": &v.Salt, "verifier": &v.Verifier} {
+		if n.Sign() <= 0 || len(n.Bytes()) > maxValueLen {
+			return ErrorMalformed(fmt.Sprintf("%s must be positive and at most %d bytes", name, maxValueLen))
+		}
+	}
+
+	return nil
+}
+
+// Encodes a handshake message as its kind byte followed by each of
+// its fields.
+func marshalMessage(m interface{}) ([]byte, error) {
+	kind, fields, err := messageFields(m)
+	if err != nil {
+		return nil, err
+	}
+
+	w := wireWriter{kind}
+	for i := range fields {
+		b, err := fields[i].encode()
+		if err != nil {
+			return nil, err
+		}
+
+		w.write(b)
+	}
+
+	return w, nil
+}
+
+func unmarshalMessage(data []byte, m interface{}) error {
+	kind, fields, err := messageFields(m)
+	if err != nil {
+		return err
+	}
+
+	r := wireReader{b: data}
+	r.kind(kind)
+
+	values := make([][]byte, len(fields))
+	for i := range fields {
+		values[i] = r.read(fields[i].limit())
+	}
+
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	return setFields(fields, values)
+}
+
+// The JSON forms of the handshake messages are objects with the same
+// field names as the structs. Byte values are upper case hex, versions
+// are numbers and lists are arrays of strings. Optional byte values
+// are left out when empty. Decoding accepts hex of either case, but
+// rejects unknown or missing fields.
+func marshalMessageJSON(m interface{}) ([]byte, error) {
+	_, fields, err := messageFields(m)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []byte{'{'}
+	for i := range fields {
+		b, err := fields[i].encode()
+		if err != nil {
+			return nil, err
+		} else if fields[i].optional && len(b) == 0 {
+			continue
+		}
+
+		var value interface{} = string(b)
+		switch {
+		case fields[i].bytes != nil:
+			value = fmt.Sprintf("%X", b)
+		case fields[i].version != nil:
+			value = b[0]
+		case fields[i].list != nil:
+			list, _ := readlist(b)
+			value = append([]string{}, list...)
+		}
+
+		name, _ := json.Marshal(fields[i].name)
+		encoded, _ := json.Marshal(value)
+
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		out = append(append(append(out, name...), ':'), encoded...)
+	}
+
+	return append(out, '}'), nil
+}
+
+func unmarshalMessageJSON(data []byte, m interface{}) error {
+	_, fields, err := messageFields(m)
+	if err != nil {
+		return err
+	}
+
+	var j map[string]json.RawMessage
+	if err := json.Unmarshal(data, &j); err != nil {
+		return ErrorMalformed("not a JSON object")
+	}
+
+	values := make([][]byte, len(fields))
+	found := 0
+	for i, f := range fields {
+		raw, ok := j[f.name]
+		if !ok && f.optional {
+			continue
+		} else if !ok || string(raw) == "null" {
+			return ErrorMalformed(fmt.Sprintf("missing field %s", f.name))
+		}
+		found++
+
+		switch {
+		case f.version != nil:
+			var version uint8
+			if err = json.Unmarshal(raw, &version); err != nil {
+				return ErrorMalformed(fmt.Sprintf("%s is not a number from 0 to 255", f.name))
+			}
+			values[i] = []byte{version}
+		case f.list != nil:
+			var list []string
+			if err = json.Unmarshal(raw, &list); err != nil {
+				return ErrorMalformed(fmt.Sprintf("%s is not an array of strings", f.name))
+			}
+
+			l := messageField{name: f.name, list: &list}
+			if values[i], err = l.encode(); err != nil {
+				return err
+			}
+		default:
+			var value string
+			if err = json.Unmarshal(raw, &value); err != nil {
+				return ErrorMalformed(fmt.Sprintf("%s is not a string", f.name))
+			}
+
+			if f.text != nil {
+				values[i] = []byte(value)
+			} else if values[i], err = hex.DecodeString(value); err != nil {
+				return ErrorMalformed(fmt.Sprintf("%s is not valid hex", f.name))
+			}
+		}
+	}
+
+	if found != len(j) {
+		return ErrorMalformed("unknown field in JSON object")
+	}
+
+	return setFields(fields, values)
+}
+
+// The text forms of the handshake messages are their binary forms in
+// unpadded base64url.
+func unmarshalMessageText(text []byte, m interface{}) error {
+	b, err := unmarshaltext(text)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalMessage(b, m)
+}
+
+func (c *ClientHello) MarshalBinary() ([]byte, error) {
+	return marshalMessage(c)
+}
+
+func (c *ClientHello) UnmarshalBinary(data []byte) error {
+	return unmarshalMessage(data, c)
+}
+
+func (c ClientHello) MarshalJSON() ([]byte, error) {
+	return marshalMessageJSON(&c)
+}
+
+func (c *ClientHello) UnmarshalJSON(data []byte) error {
+	return unmarshalMessageJSON(data, c)
+}
+
+func (c *ClientHello) MarshalText() ([]byte, error) {
+	return marshaltext(marshalMessage(c))
+}
+
+func (c *ClientHello) UnmarshalText(text []byte) error {
+	return unmarshalMessageText(text, c)
+}
+
+func (sc *ServerChallenge) MarshalBinary() ([]byte, error) {
+	return marshalMessage(sc)
+}
+
+func (sc *ServerChallenge) UnmarshalBinary(data []byte) error {
+	return unmarshalMessage(data, sc)
+}
+
+func (sc ServerChallenge) MarshalJSON() ([]byte, error) {
+	return marshalMessageJSON(&sc)
+}
+
+func (sc *ServerChallenge) UnmarshalJSON(data []byte) error {
+	return unmarshalMessageJSON(data, sc)
+}
+
+func (sc *ServerChallenge) MarshalText() ([]byte, error) {
+	return marshaltext(marshalMessage(sc))
+}
+
+func (sc *ServerChallenge) UnmarshalText(text []byte) error {
+	return unmarshalMessageText(text, sc)
+}
+
+func (p *ClientProof) MarshalBinary() ([]byte, error) {
+	return marshalMessage(p)
+}
+
+func (p *ClientProof) UnmarshalBinary(data []byte) error {
+	return unmarshalMessage(data, p)
+}
+
+func (p ClientProof) MarshalJSON() ([]byte, error) {
+	return marshalMessageJSON(&p)
+}
+
+func (p *ClientProof) UnmarshalJSON(data []byte) error {
+	return unmarshalMessageJSON(data, p)
+}
+
+func (p *ClientProof) MarshalText() ([]byte, error) {
+	return marshaltext(marshalMessage(p))
+}
+
+func (p *ClientProof) UnmarshalText(text []byte) error {
+	return unmarshalMessageText(text, p)
+}
+
+func (p *ServerProof) MarshalBinary() ([]byte, error) {
+	return marshalMessage(p)
+}
+
+func (p *ServerProof) UnmarshalBinary(data []byte) error {
+	return unmarshalMessage(data, p)
+}
+
+func (p ServerProof) MarshalJSON() ([]byte, error) {
+	return marshalMessageJSON(&p)
+}
+
+func (p *ServerProof) UnmarshalJSON(data []byte) error {
+	return unmarshalMessageJSON(data, p)
+}
+
+func (p *ServerProof) MarshalText() ([]byte, error) {
+	return marshaltext(marshalMessage(p))
+}
+
+func (p *ServerProof) UnmarshalText(text []byte) error {
+	return unmarshalMessageText(text, p)
+}
+
+func (e *ErrorMessage) MarshalBinary() ([]byte, error) {
+	return marshalMessage(e)
+}
+
+func (e *ErrorMessage) UnmarshalBinary(data []byte) error {
+	return unmarshalMessage(data, e)
+}
+
+func (e ErrorMessage) MarshalJSON() ([]byte, error) {
+	return marshalMessageJSON(&e)
+}
+
+func (e *ErrorMessage) UnmarshalJSON(data []byte) error {
+	return unmarshalMessageJSON(data, e)
+}
+
+func (o *Offer) MarshalBinary() ([]byte, error) {
+	return marshalMessage(o)
+}
+
+func (o *Offer) UnmarshalBinary(data []byte) error {
+	return unmarshalMessage(data, o)
+}
+
+func (o Offer) MarshalJSON() ([]byte, error) {
+	return marshalMessageJSON(&o)
+}
+
+func (o *Offer) UnmarshalJSON(data []byte) error {
+	return unmarshalMessageJSON(data, o)
+}
+
+func (o *Offer) MarshalText() ([]byte, error) {
+	return marshaltext(marshalMessage(o))
+}
+
+func (o *Offer) UnmarshalText(text []byte) error {
+	return unmarshalMessageText(text, o)
+}
+
+func (s *Selection) MarshalBinary() ([]byte, error) {
+	return marshalMessage(s)
+}
+
+func (s *Selection) UnmarshalBinary(data []byte) error {
+	return unmarshalMessage(data, s)
+}
+
+func (s Selection) MarshalJSON() ([]byte, error) {
+	return marshalMessageJSON(&s)
+}
+
+func (s *Selection) UnmarshalJSON(data []byte) error {
+	return unmarshalMessageJSON(data, s)
+}
+
+func (s *Selection) MarshalText() ([]byte, error) {
+	return marshaltext(marshalMessage(s))
+}
+
+func (s *Selection) UnmarshalText(text []byte) error {
+	return unmarshalMessageText(text, s)
+}
@@ -0,0 +1,147 @@
+package libgosrp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func testverifier(t *testing.T) Verifier {
+	config, _ := GetProfile(ProfileRFC5054)
+
+	var tmpv Verifier
+	if _, err := tmpv.New("alice", "password123", 16, config); err != nil {
+		t.Fatal(err)
+	}
+
+	return tmpv
+}
+
+func TestVerifierEncodings(t *testing.T) {
+	tmpv := testverifier(t)
+
+	bin, err := tmpv.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := tmpv.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsondata, err := json.Marshal(tmpv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Logf("Binary form is %d bytes, text form is %d bytes.", len(bin), len(text))
+	t.Logf("JSON: %s", jsondata)
+
+	var frombin, fromtext, fromjson Verifier
+	if err = frombin.UnmarshalBinary(bin); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = fromtext.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = json.Unmarshal(jsondata, &fromjson); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, decoded := range map[string]Verifier{"binary": frombin, "text": fromtext, "JSON": fromjson} {
+		if !reflect.DeepEqual(decoded, tmpv) {
+			t.Errorf("Error: verifier changed after %s round trip.\nExpected: %+v\nGot: %+v", name, tmpv, decoded)
+		}
+	}
+
+	again, _ := frombin.MarshalBinary()
+	if !bytes.Equal(again, bin) {
+		t.Error("Error: binary form is not canonical.")
+	}
+}
+
+func TestMalformedVerifiers(t *testing.T) {
+	tmpv := testverifier(t)
+	bin, _ := tmpv.MarshalBinary()
+
+	oversized := wireWriter{kindVerifier, VerifierVersion}
+	oversized.write([]byte("alice"))
+	oversized.write(make([]byte, maxValueLen+1))
+
+	//salt field with a leading zero
+	padded := append(wireWriter{kindVerifier, VerifierVersion}, bin[2:9]...)
+	padded.write(append([]byte{0}, tmpv.Salt.Bytes()...))
+	padded = append(padded, bin[9+2+len(tmpv.Salt.Bytes()):]...)
+
+	malformed := map[string][]byte{
+		"empty":        {},
+		"wrong kind":   append([]byte{kindClientProof}, bin[1:]...),
+		"truncated":    bin[:len(bin)-3],
+		"trailing":     append(append([]byte{}, bin...), 0),
+		"version":      append([]byte{kindVerifier, VerifierVersion + 1}, bin[2:]...),
+		"oversized":    oversized,
+		"leading zero": padded,
+	}
+
+	for name, data := range malformed {
+		var v Verifier
+		if err := v.UnmarshalBinary(data); err == nil {
+			t.Errorf("Error: accepted %s verifier.", name)
+		}
+	}
+
+	var v Verifier
+	for _, data := range []string{
+		`{"version": 1, "I": "alice", "salt": "zz", "verifier": "01"}`,
+		`{"version": 1, "I": "alice", "salt": "01", "verifier": "01", "group": "rfc5054-2048"}`,
+		`{"version": 1, "I": "", "salt": "01", "verifier": "01"}`,
+	} {
+		if err := json.Unmarshal([]byte(data), &v); err == nil {
+			t.Errorf("Error: accepted JSON verifier %s", data)
+		}
+	}
+}
+
+func TestMessageEncodings(t *testing.T) {
+	messages := []interface {
+		MarshalBinary() ([]byte, error)
+		UnmarshalBinary([]byte) error
+	}{
+		&ClientHello{"alice", []byte{0x00, 0xAB}},
+		&ServerChallenge{testhex("BEB25379D1A8581EB5A727673A2441EE"), []byte{0x01, 0x02}},
+		&ClientProof{testhex("3F3BC67169EA71302599CF1B0F5D408B7B65D347")},
+		&ServerProof{testhex("9CAB3C575A11DE37D3AC1421A9F009236A48EB55")},
+	}
+
+	for _, m := range messages {
+		bin, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := reflect.New(reflect.TypeOf(m).Elem()).Interface().(interface {
+			UnmarshalBinary([]byte) error
+		})
+
+		if err = decoded.UnmarshalBinary(bin); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(decoded, m) {
+			t.Errorf("Error: message changed after round trip.\nExpected: %+v\nGot: %+v", m, decoded)
+		}
+
+		if err = decoded.UnmarshalBinary(append(bin, 0)); err == nil {
+			t.Errorf("Error: accepted %T with trailing data.", m)
+		}
+	}
+
+	var c ClientHello
+	if err := json.Unmarshal([]byte(`{"I": "alice", "A": "not hex"}`), &c); err == nil {
+		t.Error("Error: accepted challenge with a malformed A.")
+	}
+}
@@ -0,0 +1,53 @@
+package libgosrp
+
+import (
+	"strings"
+)
+
+// Sent by either side in place of the next handshake message when
+// the handshake fails. Code is one of the Code* constants.
+type ErrorMessage struct {
+	Code    string
+	Message string
+}
+
+const (
+	CodeBadProof         = "bad_proof"
+	CodeIllegalParameter = "illegal_parameter"
+	CodeSessionState     = "session_state"
+	CodeMalformed        = "malformed"
+	CodeBusy             = "busy"
+	CodeThrottled        = "throttled"
+	CodeInternal         = "internal"
+)
+
+// Returns the ErrorMessage to send to the peer for err.
+func NewErrorMessage(err error) ErrorMessage {
+	code := CodeInternal
+
+	switch err.(type) {
+	case ErrorBadProof:
+		code = CodeBadProof
+	case ErrorIllegalParameter:
+		code = CodeIllegalParameter
+	case ErrorSessionState, ErrorUsernameMismatch:
+		code = CodeSessionState
+	case ErrorMalformed:
+		code = CodeMalformed
+	case ErrorServerBusy:
+		code = CodeBusy
+	case ErrorThrottled:
+		code = CodeThrottled
+	}
+
+	message := strings.ToValidUTF8(err.Error(), "")
+	if len(message) > maxStringLen {
+		message = strings.ToValidUTF8(message[:maxStringLen], "")
+	}
+
+	return ErrorMessage{code, message}
+}
+
+func (e ErrorMessage) Error() string {
+	return "SRP peer reported an error (" + e.Code + "): " + e.Message
+}
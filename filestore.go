@@ -0,0 +1,182 @@
+package libgosrp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A VerifierStore kept in a JSON file: an array of verifiers in their
+// JSON encoding, sorted by username. The whole file is held in memory
+// and rewritten on every change, replacing the old file atomically, so
+// it suits small user lists such as service accounts; use PutBatch to
+// add many users at once. Only one FileStore should have a given file
+// open at a time.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+	mem  *MemoryStore
+}
+
+// Opens the store in the file at path, which is created by the first
+// Put if it doesn't exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	f := &FileStore{path: path, mem: NewMemoryStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var verifiers []Verifier
+	if err = json.Unmarshal(data, &verifiers); err != nil {
+		return nil, err
+	}
+
+	for _, v := range verifiers {
+		if err = f.mem.Put(context.Background(), v); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (f *FileStore) Get(ctx context.Context, i string) (Verifier, error) {
+	return f.mem.Get(ctx, i)
+}
+
+func (f *FileStore) Put(ctx context.Context, v Verifier) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old, err := f.mem.Get(ctx, v.I)
+	existed := err == nil
+
+	if err = f.mem.Put(ctx, v); err != nil {
+		return err
+	}
+
+	if err = f.save(ctx); err != nil {
+		//put back what was there before
+		if existed {
+			f.mem.Put(ctx, old)
+		} else {
+			f.mem.Delete(ctx, v.I)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Stores the verifiers with a single rewrite of the file. Either all
+// of them are stored or, on error, none are.
+func (f *FileStore) PutBatch(ctx context.Context, verifiers []Verifier) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	//what was there before, or nothing for new users
+	old := make(map[string]*Verifier, len(verifiers))
+	for _, v := range verifiers {
+		if _, seen := old[v.I]; seen {
+			continue
+		}
+
+		old[v.I] = nil
+		if prev, err := f.mem.Get(ctx, v.I); err == nil {
+			old[v.I] = &prev
+		}
+	}
+
+	var err error
+	for _, v := range verifiers {
+		if err = f.mem.Put(ctx, v); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = f.save(ctx)
+	}
+
+	if err != nil {
+		for i, prev := range old {
+			if prev != nil {
+				f.mem.Put(ctx, *prev)
+			} else {
+				f.mem.Delete(ctx, i)
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStore) Delete(ctx context.Context, i string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old, err := f.mem.Get(ctx, i)
+	if err != nil {
+		return err
+	}
+
+	f.mem.Delete(ctx, i)
+	if err = f.save(ctx); err != nil {
+		f.mem.Put(ctx, old)
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStore) List(ctx context.Context) ([]string, error) {
+	return f.mem.List(ctx)
+}
+
+// Writes the verifiers to a temporary file beside the store, then
+// renames it over the store.
+func (f *FileStore) save(ctx context.Context) error {
+	names, _ := f.mem.List(ctx)
+
+	verifiers := make([]Verifier, 0, len(names))
+	for _, i := range names {
+		v, _ := f.mem.Get(ctx, i)
+		verifiers = append(verifiers, v)
+	}
+
+	data, err := json.MarshalIndent(verifiers, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(append(data, '\n')); err == nil {
+		err = tmp.Sync()
+	}
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,130 @@
+package libgosrp
+
+import (
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// PEM block type used by openssl dhparam.
+const pemDHParameters = "DH PARAMETERS"
+
+// DHParameter from PKCS #3.
+type dhParameter struct {
+	P, G               *big.Int
+	PrivateValueLength int `asn1:"optional"`
+}
+
+// Generates a new Nsize-bit safe prime N = 2q+1, with g the smallest
+// generator of the full multiplicative group. This can take minutes
+// for the larger sizes.
+func GenerateGroupParameters(Nsize int) (SRPGroupParameters, error) {
+	var gp SRPGroupParameters
+
+	if Nsize < 16 {
+		return gp, ErrorInvalidGroup(fmt.Sprintf("cannot generate a %d-bit group", Nsize))
+	}
+
+	one := big.NewInt(1)
+	for {
+		q, err := rand.Prime(rand.Reader, Nsize-1)
+		if err != nil {
+			return gp, err
+		}
+
+		gp.N.Lsh(q, 1)
+		gp.N.Add(&gp.N, one)
+
+		if gp.N.BitLen() == Nsize && gp.N.ProbablyPrime(primalityRounds) {
+			break
+		}
+	}
+
+	//g generates the whole group iff g^q = N-1
+	q := new(big.Int).Rsh(&gp.N, 1)
+	nminus1 := new(big.Int).Sub(&gp.N, one)
+	for gp.G.SetInt64(2); ; gp.G.Add(&gp.G, one) {
+		if new(big.Int).Exp(&gp.G, q, &gp.N).Cmp(nminus1) == 0 {
+			return gp, nil
+		}
+	}
+}
+
+// Encodes the group as PKCS #3 DH parameters in DER.
+func (gp *SRPGroupParameters) MarshalDER() ([]byte, error) {
+	return asn1.Marshal(dhParameter{P: &gp.N, G: &gp.G})
+}
+
+// Encodes the group as PEM, in the format written by openssl dhparam.
+func (gp *SRPGroupParameters) MarshalPEM() ([]byte, error) {
+	der, err := gp.MarshalDER()
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: pemDHParameters, Bytes: der}), nil
+}
+
+// Reads PKCS #3 DH parameters encoded in DER. The group is not
+// validated; see Validate().
+func ParseGroupParametersDER(der []byte) (SRPGroupParameters, error) {
+	var p dhParameter
+
+	rest, err := asn1.Unmarshal(der, &p)
+	if err != nil {
+		return SRPGroupParameters{}, err
+	} else if len(rest) > 0 {
+		return SRPGroupParameters{}, ErrorInvalidGroup("trailing data after DH parameters")
+	}
+
+	if p.P == nil || p.G == nil || p.P.Sign() <= 0 || p.G.Sign() <= 0 {
+		return SRPGroupParameters{}, ErrorInvalidGroup("N and g must be positive")
+	}
+
+	return SRPGroupParameters{*p.P, *p.G}, nil
+}
+
+// Reads DH parameters in the PEM format written by openssl dhparam.
+// The group is not validated; see Validate().
+func ParseGroupParametersPEM(data []byte) (SRPGroupParameters, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemDHParameters {
+		return SRPGroupParameters{}, ErrorInvalidGroup("no " + pemDHParameters + " PEM block found")
+	}
+
+	return ParseGroupParametersDER(block.Bytes)
+}
+
+type groupJSON struct {
+	N string
+	G string
+}
+
+// Encodes the group as {"N": ..., "G": ...}, with both values in upper
+// case hex.
+func (gp SRPGroupParameters) MarshalJSON() ([]byte, error) {
+	return json.Marshal(groupJSON{fmt.Sprintf("%X", gp.N.Bytes()), fmt.Sprintf("%X", gp.G.Bytes())})
+}
+
+func (gp *SRPGroupParameters) UnmarshalJSON(data []byte) error {
+	var g groupJSON
+	if err := json.Unmarshal(data, &g); err != nil {
+		return err
+	}
+
+	var n, generator big.Int
+	if _, ok := n.SetString(g.N, 16); !ok || n.Sign() <= 0 {
+		return ErrorInvalidGroup("N is not a positive hex number")
+	}
+
+	if _, ok := generator.SetString(g.G, 16); !ok || generator.Sign() <= 0 {
+		return ErrorInvalidGroup("g is not a positive hex number")
+	}
+
+	gp.N = n
+	gp.G = generator
+	return nil
+}
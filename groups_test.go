@@ -0,0 +1,81 @@
+package libgosrp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGenerateGroupParameters(t *testing.T) {
+	gp, err := GenerateGroupParameters(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gp.N.BitLen() != 256 {
+		t.Errorf("Error: expected a 256-bit N. Got %d bits", gp.N.BitLen())
+	}
+
+	if err = gp.Validate(256); err != nil {
+		t.Error(err)
+	}
+}
+
+// Written by openssl dhparam 512
+const openssldhparam = `-----BEGIN DH PARAMETERS-----
+MEYCQQDqq0C28UI5/jFLT+8KynwwQ7EdXiJAlE2gOOYx5Zr1M/Kr8bR+Z6MFOXdA
+/0XUCYMhi9XuoL+DBgrAdcOoB4K3AgEC
+-----END DH PARAMETERS-----
+`
+
+func TestParseOpenSSLGroup(t *testing.T) {
+	gp, err := ParseGroupParametersPEM([]byte(openssldhparam))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gp.G.Int64() != 2 || gp.N.BitLen() != 512 {
+		t.Errorf("Error: parsed g = %v with a %d-bit N.", &gp.G, gp.N.BitLen())
+	}
+
+	if err = gp.Validate(512); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroupEncodings(t *testing.T) {
+	gp, _ := GetGroupParameters(2048)
+
+	pemdata, err := gp.MarshalPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frompem, err := ParseGroupParametersPEM(pemdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsondata, err := json.Marshal(gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromjson SRPGroupParameters
+	if err = json.Unmarshal(jsondata, &fromjson); err != nil {
+		t.Fatal(err)
+	}
+
+	if !frompem.Equal(gp) || !fromjson.Equal(gp) {
+		t.Error("Error: group changed after encoding and decoding.")
+	}
+
+	t.Logf("PEM:\n%s", pemdata)
+
+	if _, err = ParseGroupParametersPEM([]byte("not pem")); err == nil {
+		t.Error("Error: parsed group from garbage.")
+	}
+
+	if err = json.Unmarshal([]byte(`{"N": "-17", "G": "2"}`), &fromjson); err == nil {
+		t.Error("Error: accepted a negative N.")
+	}
+}
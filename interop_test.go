@@ -0,0 +1,102 @@
+package libgosrp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Transcript of a handshake, as stored in testdata. All values are
+// upper case hex.
+type transcript struct {
+	//library and version that wrote the transcript
+	Generator          string `json:"generator"`
+	I, P               string
+	Salt               string `json:"salt"`
+	LittleA            string `json:"a"`
+	LittleB            string `json:"b"`
+	V, A, B, K, M1, M2 string
+}
+
+func readtranscript(t *testing.T, profile string) transcript {
+	var tr transcript
+
+	data, err := os.ReadFile(filepath.Join("testdata", profile+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = json.Unmarshal(data, &tr); err != nil {
+		t.Fatal(err)
+	}
+
+	return tr
+}
+
+func fixedgen(value string) func(uint) (big.Int, error) {
+	return func(uint) (big.Int, error) {
+		var n big.Int
+		n.SetString(value, 16)
+		return n, nil
+	}
+}
+
+// Replays the transcripts in testdata, handshakes with the default
+// settings of other SRP libraries, through both the client and the
+// server. testdata/regen.sh writes them from the libraries; each
+// names what wrote it, and those that don't come from the library
+// itself only show agreement with a port of its routines.
+func TestInteropProfiles(t *testing.T) {
+	for _, profile := range []string{ProfilePythonSRP, ProfileNimbus, ProfileThinbus} {
+		tr := readtranscript(t, profile)
+		if strings.Contains(tr.Generator, "stand-in") || tr.Generator == "" {
+			t.Logf("%s transcript is not from the library: %q", profile, tr.Generator)
+		}
+
+		server, err := GetProfile(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.sgen = fixedgen(tr.Salt)
+		server.abgen = fixedgen(tr.LittleB)
+
+		client, _ := GetProfile(profile)
+		client.abgen = fixedgen(tr.LittleA)
+
+		var tmpv Verifier
+		_, err = tmpv.New(tr.I, tr.P, 16, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		csess, ssess := testhandshake(t, tmpv, tr.P, client, server)
+
+		got := map[string]string{
+			"v":  fmt.Sprintf("%X", tmpv.Verifier.Bytes()),
+			"A":  fmt.Sprintf("%X", csess.biga.Bytes()),
+			"B":  fmt.Sprintf("%X", ssess.bigb.Bytes()),
+			"K":  fmt.Sprintf("%X", ssess.SessionKey()),
+			"M1": fmt.Sprintf("%X", csess.m1),
+			"M2": fmt.Sprintf("%X", ssess.m2),
+		}
+
+		expected := map[string]string{
+			"v":  tr.V,
+			"A":  tr.A,
+			"B":  tr.B,
+			"K":  tr.K,
+			"M1": tr.M1,
+			"M2": tr.M2,
+		}
+
+		for name, value := range expected {
+			if got[name] != value {
+				t.Errorf("Error: %s incorrect for profile %s.\nExpected: %s\nGot: %s", name, profile, value, got[name])
+			}
+		}
+	}
+}
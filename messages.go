@@ -0,0 +1,48 @@
+package libgosrp
+
+// The handshake messages. Numbers are carried in the byte order of the
+// SRPConfig in use; see Codec for ways of putting them on the wire.
+
+// First message from the client: its username and public ephemeral
+// value.
+type ClientHello struct {
+	I string
+	A []byte
+}
+
+// Server's reply to a ClientHello: the user's salt and the server's
+// public ephemeral value.
+type ServerChallenge struct {
+	Salt []byte
+	B    []byte
+}
+
+// Client's evidence that it has derived the session key.
+type ClientProof struct {
+	M1 []byte
+}
+
+// Server's evidence that it has derived the session key.
+type ServerProof struct {
+	M2 []byte
+}
+
+// The negotiation messages, exchanged before the handshake when the
+// peers may not agree on a profile; see MakeOffer.
+
+// First message from the client: the protocol version, its username
+// and the profiles it supports, in order of preference.
+type Offer struct {
+	Version  int
+	I        string
+	Profiles []string
+}
+
+// Server's reply to an Offer: the chosen profile and, optionally, the
+// group it will use. N and G are big-endian.
+type Selection struct {
+	Version int
+	Profile string
+	N       []byte
+	G       []byte
+}
@@ -0,0 +1,74 @@
+package libgosrp
+
+import (
+	"time"
+)
+
+// Receives measurements for capacity planning and monitoring. Set on
+// the config with SRPConfig.SetMetrics: sessions and verifiers made
+// with the config report the cost of their arithmetic, and a Server
+// using it reports its handshakes too. Methods are called from the
+// code being measured, so they should be quick, and must be safe for
+// concurrent use. See PrometheusMetrics.
+type Metrics interface {
+	// A challenge was sent.
+	HandshakeStarted(group string)
+	// A client's proof was accepted.
+	HandshakeCompleted(group string)
+	// A handshake failed. reason is an ErrorMessage code, "expired"
+	// for sessions that timed out, or "unknown_user" for proofs for
+	// users who don't exist.
+	HandshakeFailed(group, reason string)
+	// A modular exponentiation took d.
+	Exp(group string, d time.Duration)
+	// Deriving x from a password took d.
+	KDF(group string, d time.Duration)
+	// The number of handshakes waiting for a proof is now n.
+	PendingSessions(n int)
+	// A user or address was locked out. kind is "user" or "addr".
+	Lockout(kind string)
+}
+
+func (c *SRPConfig) measure_exp(start time.Time) {
+	if c.metrics != nil {
+		c.metrics.Exp(c.GroupID(), time.Since(start))
+	}
+}
+
+func (c *SRPConfig) measure_kdf(start time.Time) {
+	if c.metrics != nil {
+		c.metrics.KDF(c.GroupID(), time.Since(start))
+	}
+}
+
+// Reports the handshake outcome e represents, if any.
+func (s *Server) measure(e Event) {
+	m := s.config.metrics
+	if m == nil {
+		return
+	}
+
+	group := e.Group
+	if group == "" {
+		group = s.config.GroupID()
+	}
+
+	switch e.Kind {
+	case EventChallenge, EventUnknownUser:
+		m.HandshakeStarted(group)
+		m.PendingSessions(s.Pending())
+	case EventSuccess:
+		m.HandshakeCompleted(group)
+	case EventProofFailed:
+		if e.UnknownUser {
+			m.HandshakeFailed(group, "unknown_user")
+		} else {
+			m.HandshakeFailed(group, NewErrorMessage(e.Err).Code)
+		}
+	case EventExpired:
+		m.HandshakeFailed(group, "expired")
+		m.PendingSessions(s.Pending())
+	case EventInvalidA, EventThrottled:
+		m.HandshakeFailed(group, NewErrorMessage(e.Err).Code)
+	}
+}
@@ -0,0 +1,166 @@
+package libgosrp
+
+import (
+	"fmt"
+)
+
+// Version of the negotiation messages spoken by this package. Peers
+// settle on the lower of their two versions.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+type ErrorUnsupportedVersion int
+
+func (e ErrorUnsupportedVersion) Error() string {
+	return fmt.Sprintf("Unsupported SRP protocol version %d. Supported versions are %d to %d.", int(e), MinProtocolVersion, ProtocolVersion)
+}
+
+type ErrorNoCommonProfile string
+
+func (e ErrorNoCommonProfile) Error() string {
+	return fmt.Sprintf("No SRP profile supported by both peers (offered: %s).", string(e))
+}
+
+type ErrorUntrustedGroup string
+
+func (e ErrorUntrustedGroup) Error() string {
+	return fmt.Sprintf("Server offered a group that is not trusted: %s.", string(e))
+}
+
+// Returns the client's Offer, to be sent with a Codec.
+func MakeOffer(i string, profiles []string) (Offer, error) {
+	if i == "" {
+		return Offer{}, new(EmptyUsernameError)
+	}
+
+	return Offer{ProtocolVersion, i, profiles}, nil
+}
+
+// Picks the first profile from the client's Offer that is also in
+// supported, and returns its SRPConfig along with the username and the
+// Selection for the client. If send_group is set, the Selection
+// carries N and g.
+func SelectProfile(o Offer, supported []string, send_group bool) (*SRPConfig, string, Selection, error) {
+	if o.Version < MinProtocolVersion {
+		return nil, "", Selection{}, ErrorUnsupportedVersion(o.Version)
+	}
+
+	if o.I == "" {
+		return nil, "", Selection{}, new(EmptyUsernameError)
+	}
+
+	profile := ""
+	for _, p := range o.Profiles {
+		if contains(supported, p) {
+			profile = p
+			break
+		}
+	}
+
+	if profile == "" {
+		return nil, "", Selection{}, ErrorNoCommonProfile(fmt.Sprint(o.Profiles))
+	}
+
+	config, err := GetProfile(profile)
+	if err != nil {
+		return nil, "", Selection{}, err
+	}
+
+	s := Selection{Version: ProtocolVersion, Profile: profile}
+	if o.Version < s.Version {
+		s.Version = o.Version
+	}
+
+	if send_group {
+		s.N = config.gp.N.Bytes()
+		s.G = config.gp.G.Bytes()
+	}
+
+	return config, o.I, s, nil
+}
+
+// Reads the server's Selection, checking that the profile is one the
+// client offered and that any group sent is either the profile's own
+// or in trusted. If trusted is nil, TrustedGroups() is used. Trusted
+// groups not built into this package must also pass
+// Validate(MinGroupBits).
+func ReadSelection(s Selection, offered []string, trusted []SRPGroupParameters) (*SRPConfig, error) {
+	if s.Version < MinProtocolVersion || s.Version > ProtocolVersion {
+		return nil, ErrorUnsupportedVersion(s.Version)
+	}
+
+	if !contains(offered, s.Profile) {
+		return nil, ErrorNoCommonProfile(fmt.Sprint(offered))
+	}
+
+	config, err := GetProfile(s.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.N) == 0 && len(s.G) == 0 {
+		return config, nil
+	} else if len(s.N) == 0 || len(s.G) == 0 {
+		return nil, ErrorUntrustedGroup("N or g is missing")
+	}
+
+	var gp SRPGroupParameters
+	gp.N.SetBytes(s.N)
+	gp.G.SetBytes(s.G)
+
+	if gp.Equal(config.gp) {
+		return config, nil
+	}
+
+	if trusted == nil {
+		trusted = TrustedGroups()
+	}
+
+	for _, t := range trusted {
+		if !gp.Equal(t) {
+			continue
+		}
+
+		if !gp.IsKnown() {
+			if err = gp.Validate(MinGroupBits); err != nil {
+				return nil, err
+			}
+		}
+
+		config.gp = gp
+		return config, nil
+	}
+
+	return nil, ErrorUntrustedGroup(fmt.Sprintf("%d-bit N with g = %v", gp.N.BitLen(), &gp.G))
+}
+
+// Returns the groups defined by this package.
+func TrustedGroups() []SRPGroupParameters {
+	var groups []SRPGroupParameters
+
+	for _, size := range []int{1024, 1536, 2048, 3072, 4096, 6144, 8192} {
+		gp, err := GetGroupParameters(size)
+		if err == nil {
+			groups = append(groups, gp)
+		}
+	}
+
+	return groups
+}
+
+// Returns true if both groups have the same N and g.
+func (gp *SRPGroupParameters) Equal(other SRPGroupParameters) bool {
+	return gp.N.Cmp(&other.N) == 0 && gp.G.Cmp(&other.G) == 0
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+package libgosrp
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestNegotiation(t *testing.T) {
+	offer, err := MakeOffer("alice", []string{ProfileNimbus, ProfileRFC5054})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the messages go through a codec between the peers
+	codec := BinaryCodec{}
+	data, err := codec.Marshal(&offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received Offer
+	if err = codec.Unmarshal(data, &received); err != nil {
+		t.Fatal(err)
+	}
+
+	sconfig, i, selection, err := SelectProfile(received, []string{ProfileRFC5054, ProfileWoW}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err = codec.Marshal(&selection); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent Selection
+	if err = codec.Unmarshal(data, &sent); err != nil {
+		t.Fatal(err)
+	}
+
+	if i != "alice" {
+		t.Errorf("Error: username should be alice. Got %v", i)
+	}
+
+	if sconfig.Profile() != ProfileRFC5054 {
+		t.Errorf("Error: expected profile %s. Got %s", ProfileRFC5054, sconfig.Profile())
+	}
+
+	cconfig, err := ReadSelection(sent, []string{ProfileNimbus, ProfileRFC5054}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cconfig.Profile() != ProfileRFC5054 || !cconfig.gp.Equal(sconfig.gp) {
+		t.Error("Error: client and server configs differ after negotiation.")
+	}
+
+	if _, _, _, err = SelectProfile(offer, []string{ProfileWoW}, false); err == nil {
+		t.Error("Error: negotiation succeeded with no common profile.")
+	}
+
+	if _, err = ReadSelection(selection, []string{ProfileNimbus}, nil); err == nil {
+		t.Error("Error: client accepted a profile it did not offer.")
+	}
+}
+
+func TestUntrustedGroup(t *testing.T) {
+	gp, _ := GetGroupParameters(1024)
+	untrusted := Selection{Version: 1, Profile: ProfileRFC5054, N: gp.N.Bytes(), G: []byte{3}}
+
+	_, err := ReadSelection(untrusted, []string{ProfileRFC5054}, nil)
+	if _, ok := err.(ErrorUntrustedGroup); !ok {
+		t.Errorf("Error: expected ErrorUntrustedGroup. Got %v", err)
+	}
+
+	trusted := Selection{Version: 1, Profile: ProfileRFC5054, N: gp.N.Bytes(), G: []byte{2}}
+	config, err := ReadSelection(trusted, []string{ProfileRFC5054}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !config.gp.Equal(gp) {
+		t.Error("Error: client did not switch to the group sent by the server.")
+	}
+
+	//groups trusted by the caller are still validated
+	var bogus SRPGroupParameters
+	big2048, _ := GetGroupParameters(2048)
+	bogus.N.Add(&big2048.N, big.NewInt(2))
+	bogus.G.SetInt64(2)
+	sent := Selection{Version: 1, Profile: ProfileRFC5054, N: bogus.N.Bytes(), G: []byte{2}}
+	if _, err = ReadSelection(sent, []string{ProfileRFC5054}, []SRPGroupParameters{bogus}); err == nil {
+		t.Error("Error: client accepted a trusted group that isn't a safe prime.")
+	} else if _, ok := err.(ErrorInvalidGroup); !ok {
+		t.Error("Error: wrong error for an invalid trusted group:", err)
+	}
+
+	future := Selection{Version: 99, Profile: ProfileRFC5054}
+	if _, err = ReadSelection(future, []string{ProfileRFC5054}, nil); err == nil {
+		t.Error("Error: client accepted an unknown protocol version.")
+	}
+
+	halfgroup := Selection{Version: 1, Profile: ProfileRFC5054, N: gp.N.Bytes()}
+	if _, err = ReadSelection(halfgroup, []string{ProfileRFC5054}, nil); err == nil {
+		t.Error("Error: client accepted N without g.")
+	}
+}
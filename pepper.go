@@ -0,0 +1,189 @@
+package libgosrp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// Server-held secret keys that encrypt stored verifiers, so that a
+// copy of the verifier database alone isn't enough to guess passwords
+// offline. Each key has an id, recorded in Verifier.Pepper of the
+// verifiers it sealed, so keys can be rotated: verifiers are sealed
+// with the current key and opened with whichever key sealed them.
+//
+// A verifier is sealed by adding a mask to it mod N, derived with
+// HMAC-SHA-256 from the key, the username and the salt. The result is
+// a number mod N like any verifier, so every store and encoding keeps
+// sealed verifiers as it does others. Clients are unaffected; servers
+// hand the Pepper to SRPConfig.SetPepper.
+//
+// Add every key before the Pepper is used, after which it's safe for
+// concurrent use.
+type Pepper struct {
+	current string
+	keys    map[string][]byte
+}
+
+type ErrorUnknownPepper string
+
+func (e ErrorUnknownPepper) Error() string {
+	return fmt.Sprintf("Verifier is sealed with pepper %q, which is not configured.", string(e))
+}
+
+// Returns a Pepper sealing verifiers with key, which is identified by
+// id and must be at least 32 bytes.
+func NewPepper(id string, key []byte) (*Pepper, error) {
+	p := &Pepper{keys: make(map[string][]byte)}
+	if err := p.AddKey(id, key); err != nil {
+		return nil, err
+	}
+
+	p.current = id
+	return p, nil
+}
+
+// Adds an earlier key, used only to open verifiers sealed with it.
+func (p *Pepper) AddKey(id string, key []byte) error {
+	if id == "" {
+		return ErrorMalformed("empty pepper id")
+	} else if err := checktext("pepper id", id); err != nil {
+		return err
+	}
+
+	if len(key) < 32 {
+		return fmt.Errorf("Pepper keys must be at least 32 bytes, not %d.", len(key))
+	}
+
+	if _, ok := p.keys[id]; ok {
+		return fmt.Errorf("Pepper %q added twice.", id)
+	}
+
+	p.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// Id of the key new verifiers are sealed with.
+func (p *Pepper) Current() string {
+	return p.current
+}
+
+// Seals v, computed with config, with the current key. A verifier
+// sealed with an earlier key is opened and sealed again.
+func (p *Pepper) Seal(v *Verifier, config *SRPConfig) error {
+	if v.Pepper == p.current {
+		return nil
+	}
+
+	if err := p.Open(v, config); err != nil {
+		return err
+	}
+
+	mask := p.mask(p.current, v, &config.gp.N)
+	defer wipe(&mask)
+
+	var sealed big.Int
+	sealed.Add(&v.Verifier, &mask)
+	sealed.Mod(&sealed, &config.gp.N)
+
+	v.Verifier = sealed
+	v.Pepper = p.current
+	return nil
+}
+
+// Reverses Seal, leaving v as computed by Verifier.New. Unsealed
+// verifiers are left alone.
+func (p *Pepper) Open(v *Verifier, config *SRPConfig) error {
+	if v.Pepper == "" {
+		return nil
+	}
+
+	if _, ok := p.keys[v.Pepper]; !ok {
+		return ErrorUnknownPepper(v.Pepper)
+	}
+
+	mask := p.mask(v.Pepper, v, &config.gp.N)
+	defer wipe(&mask)
+
+	var opened big.Int
+	opened.Sub(&v.Verifier, &mask)
+	opened.Mod(&opened, &config.gp.N)
+
+	v.Verifier = opened
+	v.Pepper = ""
+	return nil
+}
+
+// Seals every verifier in store that isn't sealed with the current
+// key, returning how many were changed. Verifiers are sealed using
+// the config of their profile, or config for those made with config
+// or without a profile.
+// Verifiers changed while this runs may be overwritten, so it's best
+// run when no passwords are being changed.
+func (p *Pepper) Rotate(ctx context.Context, store VerifierStore, config *SRPConfig) (int, error) {
+	names, err := store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, i := range names {
+		if err = ctx.Err(); err != nil {
+			return changed, err
+		}
+
+		v, err := store.Get(ctx, i)
+		if _, deleted := err.(ErrorUnknownUser); deleted {
+			continue
+		} else if err != nil {
+			return changed, err
+		} else if v.Pepper == p.current {
+			continue
+		}
+
+		vconfig := config
+		if v.Version > 0 && v.Profile != "" && (v.Profile != config.verifier_profile() || !config.gp.identified_by(v.Group)) {
+			if vconfig, err = v.Config(); err != nil {
+				return changed, err
+			}
+		}
+
+		if err = p.Seal(&v, vconfig); err != nil {
+			return changed, err
+		}
+
+		if err = store.Put(ctx, v); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+
+	return changed, nil
+}
+
+// Mask for v under key id: HMAC-SHA-256 output, counting blocks, for
+// 16 bytes more than N so the reduction mod N is unbiased.
+func (p *Pepper) mask(id string, v *Verifier, N *big.Int) big.Int {
+	mac := hmac.New(sha256.New, p.keys[id])
+	salt := v.Salt.Bytes()
+
+	var out []byte
+	for block := uint32(0); len(out) < len(N.Bytes())+16; block++ {
+		mac.Reset()
+		mac.Write([]byte("libgosrp pepper"))
+		mac.Write(binary.BigEndian.AppendUint32(nil, block))
+		mac.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v.I))))
+		mac.Write([]byte(v.I))
+		mac.Write(salt)
+		out = mac.Sum(out)
+	}
+	defer wipe_bytes(out)
+
+	var mask big.Int
+	mask.SetBytes(out)
+	mask.Mod(&mask, N)
+	return mask
+}
@@ -0,0 +1,213 @@
+package libgosrp
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestPepper(t *testing.T) {
+	client, _ := GetProfile(ProfileRFC5054)
+	server, _ := GetProfile(ProfileRFC5054)
+
+	old, _ := NewPepper("p1", bytes.Repeat([]byte{1}, 32))
+	server.SetPepper(old)
+
+	var v Verifier
+	if _, err := v.New("alice", "password123", 16, server); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Pepper != "p1" {
+		t.Fatalf("Error: new verifier sealed with %q.", v.Pepper)
+	}
+
+	plain := v.clone()
+	old.Open(&plain, server)
+	if plain.Verifier.Cmp(&v.Verifier) == 0 {
+		t.Error("Error: sealing left the verifier unchanged.")
+	}
+
+	//sealed verifiers survive encoding
+	for _, name := range []string{"JSON", "binary"} {
+		var decoded Verifier
+		var data []byte
+		var err error
+		if name == "JSON" {
+			data, _ = v.MarshalJSON()
+			err = decoded.UnmarshalJSON(data)
+		} else {
+			data, _ = v.MarshalBinary()
+			err = decoded.UnmarshalBinary(data)
+		}
+
+		if err != nil || decoded.Pepper != "p1" || decoded.Verifier.Cmp(&v.Verifier) != 0 {
+			t.Errorf("Error: %s encoding lost the pepper: %v", name, err)
+		}
+	}
+
+	testhandshake(t, v, "password123", client, server)
+
+	//a new password over a sealed record is sealed afresh
+	reused := v.clone()
+	if _, err := reused.New("alice", "hunter2", 16, server); err != nil {
+		t.Fatal(err)
+	}
+	testhandshake(t, reused, "hunter2", client, server)
+
+	if _, err := new(SRPSession).New(v, client); err == nil {
+		t.Error("Error: sealed verifier used without the pepper.")
+	} else if _, ok := err.(ErrorUnknownPepper); !ok {
+		t.Error("Error: wrong error for a missing pepper:", err)
+	}
+
+	//rotation: the old key still opens, and Seal moves to the new one
+	rotated, _ := NewPepper("p2", bytes.Repeat([]byte{2}, 32))
+	if err := rotated.AddKey("p1", bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetPepper(rotated)
+	testhandshake(t, v, "password123", client, server)
+
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Put(ctx, v)
+	store.Put(ctx, plain)
+	plain.I = "bob"
+	store.Put(ctx, plain)
+
+	if n, err := rotated.Rotate(ctx, store, server); err != nil || n != 2 {
+		t.Fatalf("Error: rotated %d verifiers: %v", n, err)
+	}
+
+	for _, i := range []string{"alice", "bob"} {
+		v, _ := store.Get(ctx, i)
+		if v.Pepper != "p2" {
+			t.Errorf("Error: %s sealed with %q after rotation.", i, v.Pepper)
+		}
+	}
+
+	v, _ = store.Get(ctx, "alice")
+	testhandshake(t, v, "password123", client, server)
+}
+
+func TestServerPepperUpgrade(t *testing.T) {
+	ctx := context.Background()
+	config, _ := GetProfile(ProfileRFC5054)
+	old, _ := NewPepper("p1", bytes.Repeat([]byte{1}, 32))
+	config.SetPepper(old)
+
+	store := NewMemoryStore()
+	var v Verifier
+	v.New("alice", "password123", 16, config)
+	store.Put(ctx, v)
+
+	rotated, _ := NewPepper("p2", bytes.Repeat([]byte{2}, 32))
+	rotated.AddKey("p1", bytes.Repeat([]byte{1}, 32))
+	config.SetPepper(rotated)
+
+	server, _ := NewServer(config, store)
+	client, _ := GetProfile(ProfileRFC5054)
+
+	upgrades := 0
+	server.SetObserver(ObserverFunc(func(ctx context.Context, e Event) {
+		if e.Kind == EventVerifierUpgraded && e.Err == nil {
+			upgrades++
+		}
+	}))
+
+	if _, _, err := testlogin(t, server, client, "alice", "wrong"); err == nil {
+		t.Fatal("Error: wrong password accepted.")
+	}
+
+	if v, _ = store.Get(ctx, "alice"); v.Pepper != "p1" {
+		t.Error("Error: verifier upgraded after a failed login.")
+	}
+
+	if _, _, err := testlogin(t, server, client, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ = store.Get(ctx, "alice"); v.Pepper != "p2" {
+		t.Errorf("Error: verifier sealed with %q after login.", v.Pepper)
+	}
+
+	if _, _, err := testlogin(t, server, client, "alice", "password123"); err != nil {
+		t.Error("Error: login failed after the upgrade:", err)
+	}
+
+	if upgrades != 1 {
+		t.Errorf("Error: %d upgrade events.", upgrades)
+	}
+}
+
+// A password changed between the hello and the proof must survive the
+// upgrade.
+func TestServerPepperUpgradeConflict(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "verifiers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sqlstore, err := OpenSQLStore(ctx, db, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlstore.Close()
+
+	for _, store := range []VerifierStore{NewMemoryStore(), sqlstore} {
+		config, _ := GetProfile(ProfileRFC5054)
+		old, _ := NewPepper("p1", bytes.Repeat([]byte{1}, 32))
+		config.SetPepper(old)
+
+		var v Verifier
+		v.New("alice", "password123", 16, config)
+		store.Put(ctx, v)
+
+		rotated, _ := NewPepper("p2", bytes.Repeat([]byte{2}, 32))
+		rotated.AddKey("p1", bytes.Repeat([]byte{1}, 32))
+		upgraded := *config
+		upgraded.SetPepper(rotated)
+
+		server, _ := NewServer(&upgraded, store)
+		client, _ := GetProfile(ProfileRFC5054)
+
+		var upgradeErr error
+		server.SetObserver(ObserverFunc(func(ctx context.Context, e Event) {
+			if e.Kind == EventVerifierUpgraded {
+				upgradeErr = e.Err
+			}
+		}))
+
+		csess, _ := new(SRPClientSession).New("alice", client)
+		hello, _ := csess.Hello()
+		id, challenge, err := server.Hello(ctx, hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//the password changes, still under the old key
+		var changed Verifier
+		changed.New("alice", "hunter2", 16, config)
+		store.Put(ctx, changed)
+
+		csess.ReadChallenge(challenge, "password123")
+		proof, _ := csess.Proof()
+		if _, err = server.Proof(ctx, id, proof); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := upgradeErr.(ErrorConflict); !ok {
+			t.Errorf("Error: %T: upgrade over a changed verifier gave %v.", store, upgradeErr)
+		}
+
+		if _, _, err = testlogin(t, server, client, "alice", "hunter2"); err != nil {
+			t.Errorf("Error: %T: new password lost to the upgrade: %v", store, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package libgosrp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+const (
+	// SRP-6a as described in RFC 5054, with SHA-1 and the 2048-bit
+	// group: x = H(s | H(I | ":" | P)), k = H(N | PAD(g)),
+	// u = H(PAD(A) | PAD(B)) and K = H(S).
+	ProfileRFC5054 = "rfc5054"
+	// SRP-6a with the session key and proofs of RFC 2945, as in the
+	// Stanford reference implementation: SHA-1, the 1024-bit group and
+	// K = SHA_Interleave(S).
+	ProfileRFC2945 = "rfc2945"
+	// SRP6 as used by World of Warcraft servers such as MaNGOS and
+	// TrinityCore: 256-bit N, g = 7, k = 3, SHA-1 over little-endian
+	// values, x = H(s | H(UPPER(I) | ":" | UPPER(P))), an interleaved
+	// session key, and 32 byte salts. Usernames should be given in
+	// upper case, as the game client sends them.
+	ProfileWoW = "wow"
+	// Defaults of the Python srp package: as rfc5054, but with no
+	// padding of g, A or B, and with leading zero bytes dropped from
+	// H(I | ":" | P) when computing x.
+	ProfilePythonSRP = "python-srp"
+	// Defaults of Nimbus SRP: SHA-256, the 2048-bit group,
+	// x = H(s | H(P)), M1 = H(A | B | S) and M2 = H(A | M1 | S).
+	ProfileNimbus = "nimbus"
+	// Defaults of Thinbus: as nimbus, but
+	// x = H(UPPER(hex(s) | hex(H(I | ":" | P)))), and values are
+	// hashed as hex strings without padding.
+	ProfileThinbus = "thinbus"
+)
+
+type ErrNoProfileAvailable string
+
+func (e ErrNoProfileAvailable) Error() string {
+	return fmt.Sprintf("No SRP profile named %q defined by this package!", string(e))
+}
+
+// Returns a new SRPConfig set up for the named profile.
+func GetProfile(name string) (*SRPConfig, error) {
+	var config *SRPConfig
+
+	switch name {
+	case ProfileRFC5054, ProfileRFC2945:
+		size := 2048
+		if name == ProfileRFC2945 {
+			size = 1024
+		}
+
+		gp, err := GetGroupParameters(size)
+		if err != nil {
+			return nil, err
+		}
+
+		config = new(SRPConfig).New(gp, SaltedHash(sha1.New, BigEndian), RandomBytes)
+		config.SetHash(sha1.New)
+		config.SetReduceB(true)
+		config.SetCredentials(UsernamePassword)
+		if name == ProfileRFC2945 {
+			config.SetSessionKeyDerivation(SessionKeyInterleave)
+		}
+	case ProfilePythonSRP:
+		gp, err := GetGroupParameters(2048)
+		if err != nil {
+			return nil, err
+		}
+
+		config = new(SRPConfig).New(gp, TrimmedSaltedHash(sha1.New, BigEndian), RandomBytes)
+		config.SetHash(sha1.New)
+		config.SetPad(false)
+		config.SetReduceB(true)
+		config.SetCredentials(UsernamePassword)
+	case ProfileNimbus, ProfileThinbus:
+		gp, err := GetGroupParameters(2048)
+		if err != nil {
+			return nil, err
+		}
+
+		config = new(SRPConfig).New(gp, SaltedHash(sha256.New, BigEndian), RandomBytes)
+		config.SetHash(sha256.New)
+		config.SetReduceB(true)
+		config.SetProofs(ProofABS)
+		config.SetCredentials(PasswordOnly)
+		if name == ProfileThinbus {
+			config.h = HexSaltedHash(sha256.New)
+			config.SetPad(false)
+			config.SetHexHashing(true)
+			config.SetCredentials(UsernamePassword)
+		}
+	case ProfileWoW:
+		gp, err := LookupGroup("wow")
+		if err != nil {
+			return nil, err
+		}
+
+		config = new(SRPConfig).New(gp, SaltedHash(sha1.New, LittleEndian), RandomBytes)
+		config.SetHash(sha1.New)
+		config.SetByteOrder(LittleEndian)
+		config.SetMultiplier(*big.NewInt(3))
+		config.SetReduceB(true)
+		config.SetSessionKeyDerivation(SessionKeyInterleave)
+		config.SetCredentials(func(i, p string) []byte {
+			return []byte(strings.ToUpper(i) + ":" + strings.ToUpper(p))
+		})
+	default:
+		return nil, ErrNoProfileAvailable(name)
+	}
+
+	config.profile = name
+	return config, nil
+}
+
+// Returns a password hash giving x = H(s | H(p)), read as an integer
+// in the given byte order. With UsernamePassword as the credentials
+// function, this is the x of RFC 2945 and RFC 5054.
+func SaltedHash(newhash func() hash.Hash, order ByteOrder) func([]byte, []byte) big.Int {
+	return salted_hash(newhash, order, false)
+}
+
+// As SaltedHash, but with leading zero bytes dropped from H(p), as
+// python-srp does by passing H(p) through an integer.
+func TrimmedSaltedHash(newhash func() hash.Hash, order ByteOrder) func([]byte, []byte) big.Int {
+	return salted_hash(newhash, order, true)
+}
+
+func salted_hash(newhash func() hash.Hash, order ByteOrder, trim bool) func([]byte, []byte) big.Int {
+	return func(to_hash, salt []byte) big.Int {
+		inner := newhash()
+		inner.Write(to_hash)
+		hashed := inner.Sum(nil)
+		if trim {
+			hashed = bytes.TrimLeft(hashed, "\x00")
+		}
+
+		outer := newhash()
+		outer.Write(salt)
+		outer.Write(hashed)
+
+		x := outer.Sum(nil)
+		if order == LittleEndian {
+			reverse(x)
+		}
+
+		var output big.Int
+		output.SetBytes(x)
+		return output
+	}
+}
+
+// As SaltedHash, but over hex strings, as done by Thinbus's
+// HexHashedXRoutine: x = H(UPPER(hex(s) | hex(H(p)))), with leading
+// zeros dropped from hex(H(p)).
+func HexSaltedHash(newhash func() hash.Hash) func([]byte, []byte) big.Int {
+	return func(to_hash, salt []byte) big.Int {
+		inner := newhash()
+		inner.Write(to_hash)
+		hashed := strings.TrimLeft(hex.EncodeToString(inner.Sum(nil)), "0")
+
+		outer := newhash()
+		outer.Write([]byte(strings.ToUpper(hex.EncodeToString(salt) + hashed)))
+
+		var output big.Int
+		output.SetBytes(outer.Sum(nil))
+		return output
+	}
+}
@@ -0,0 +1,240 @@
+package libgosrp
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Gives the salt BEB25379D1A8581EB5A727673A2441EE twice over, as a
+// little-endian integer.
+func testwowsgen(slen uint) (big.Int, error) {
+	var salt big.Int
+	salt.SetString("EE41243A6727A7B51E58A8D17953B2BEEE41243A6727A7B51E58A8D17953B2BE", 16)
+	return salt, nil
+}
+
+// Runs both sides of a handshake through the JSON adapters. Returns
+// the client and server sessions.
+func testhandshake(t *testing.T, v Verifier, p string, client, server *SRPConfig) (*SRPClientSession, *SRPSession) {
+	csess, err := new(SRPClientSession).New(v.I, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ssess, err := new(SRPSession).New(v, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := ClientAdapter{csess, JSONCodec{}}
+	sa := ServerAdapter{ssess, JSONCodec{}}
+
+	hello, err := ca.Hello()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sa.ReadHello(hello); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := sa.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ca.ReadChallenge(challenge, p); err != nil {
+		t.Fatal(err)
+	}
+
+	proof, err := ca.Proof()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sa.ReadProof(proof); err != nil {
+		t.Fatal(err)
+	}
+
+	sproof, err := sa.Proof()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ca.ReadProof(sproof); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(csess.SessionKey(), ssess.SessionKey()) {
+		t.Errorf("Error: session keys differ.\nClient: %X\nServer: %X", csess.SessionKey(), ssess.SessionKey())
+	}
+
+	return csess, ssess
+}
+
+// Checks the WoW profile against a transcript following TrinityCore's
+// SRP6 implementation.
+func TestWoWProfile(t *testing.T) {
+	server, err := GetProfile(ProfileWoW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.sgen = testwowsgen
+	server.abgen = testbgen
+
+	client, _ := GetProfile(ProfileWoW)
+	client.abgen = testagen
+
+	var tmpv Verifier
+	_, err = tmpv.New("ALICE", "password123", 32, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"v":  "E09D0F337646CBFD9E2D1566A8C0C26C1AE0A0895229A46800A39225F582FB4C",
+		"A":  "BD8317364092953D668FC4CBDBBF99A38FF9474B30311CF6176F59538BF16838",
+		"B":  "D7B61349EEF2F69CF4E48C6FC6554FDFC074843ABEE3413F0C6F259BF1D0DB4E",
+		"K":  "9ED130F20FAEC5E51D9A484DEF1DFCF80F14E511A9AB7C8410B5DF77A3186F2C0CD59341F7E3A671",
+		"M1": "647D85B7923BF1A7FDB2C0A295FE381FCDECFC26",
+		"M2": "7010D284ABCA07DA3918225A0329848E5A535560",
+	}
+
+	csess, ssess := testhandshake(t, tmpv, "password123", client, server)
+
+	got := map[string]string{
+		"v":  fmt.Sprintf("%X", server.element(&tmpv.Verifier)),
+		"A":  fmt.Sprintf("%X", client.element(&csess.biga)),
+		"B":  fmt.Sprintf("%X", server.element(&ssess.bigb)),
+		"K":  fmt.Sprintf("%X", ssess.SessionKey()),
+		"M1": fmt.Sprintf("%X", csess.m1),
+		"M2": fmt.Sprintf("%X", ssess.m2),
+	}
+
+	for name, value := range expected {
+		if got[name] != value {
+			t.Errorf("Error: %s incorrect.\nExpected: %s\nGot: %s", name, value, got[name])
+		}
+	}
+}
+
+func TestBadPassword(t *testing.T) {
+	config, _ := GetProfile(ProfileWoW)
+
+	var tmpv Verifier
+	_, err := tmpv.New("ALICE", "password123", 32, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csess, _ := new(SRPClientSession).New("ALICE", config)
+	ssess, _ := new(SRPSession).New(tmpv, config)
+
+	hello, _ := csess.Hello()
+	if err = ssess.ReadHello(hello); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, _ := ssess.Challenge()
+	if err = csess.ReadChallenge(challenge, "password124"); err != nil {
+		t.Fatal(err)
+	}
+
+	proof, _ := csess.Proof()
+	if _, ok := ssess.ReadProof(proof).(ErrorBadProof); !ok {
+		t.Error("Error: server accepted proof made with the wrong password.")
+	}
+
+	if _, err = ssess.Proof(); err == nil {
+		t.Error("Error: server gave its proof after a failed login.")
+	}
+}
+
+// Checks the rfc5054 and rfc2945 profiles against the test vector
+// from appendix B of RFC 5054. The RFC uses the 1024-bit group.
+func TestRFC5054Profiles(t *testing.T) {
+	gp, err := GetGroupParameters(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]map[string]string{
+		ProfileRFC5054: {
+			"B":  "BD0C61512C692C0CB6D041FA01BB152D4916A1E77AF46AE105393011BAF38964DC46A0670DD125B95A981652236F99D9B681CBF87837EC996C6DA04453728610D0C6DDB58B318885D7D82C7F8DEB75CE7BD4FBAA37089E6F9C6059F388838E7A00030B331EB76840910440B1B27AAEAEEB4012B7D7665238A8E3FB004B117B58",
+			"K":  "017EEFA1CEFC5C2E626E21598987F31E0F1B11BB",
+			"M1": "3F3BC67169EA71302599CF1B0F5D408B7B65D347",
+			"M2": "9CAB3C575A11DE37D3AC1421A9F009236A48EB55",
+		},
+		ProfileRFC2945: {
+			"B":  "BD0C61512C692C0CB6D041FA01BB152D4916A1E77AF46AE105393011BAF38964DC46A0670DD125B95A981652236F99D9B681CBF87837EC996C6DA04453728610D0C6DDB58B318885D7D82C7F8DEB75CE7BD4FBAA37089E6F9C6059F388838E7A00030B331EB76840910440B1B27AAEAEEB4012B7D7665238A8E3FB004B117B58",
+			"K":  "2B8CABCEDE81B9765A37FC68FBDE512326A156512BC0DAC5FD64D2C7C3BF857A56B0C0A8CEED18C0",
+			"M1": "8B5FDB7DB0346E353689D2EDFACEC647A813E6D0",
+			"M2": "E8149A44A9D5BF552A4CC9120C545301A537F227",
+		},
+	}
+
+	for name, values := range expected {
+		server, err := GetProfile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.gp = gp
+		server.sgen = testgen
+		server.abgen = testbgen
+
+		client, _ := GetProfile(name)
+		client.gp = gp
+		client.abgen = testagen
+
+		if server.Profile() != name {
+			t.Errorf("Error: profile name should be %s. Got %s", name, server.Profile())
+		}
+
+		var tmpv Verifier
+		_, err = tmpv.New("alice", "password123", 16, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		csess, ssess := testhandshake(t, tmpv, "password123", client, server)
+
+		got := map[string]string{
+			"B":  fmt.Sprintf("%X", ssess.bigb.Bytes()),
+			"K":  fmt.Sprintf("%X", csess.SessionKey()),
+			"M1": fmt.Sprintf("%X", csess.m1),
+			"M2": fmt.Sprintf("%X", ssess.m2),
+		}
+
+		for value, correct := range values {
+			if got[value] != correct {
+				t.Errorf("Error: %s incorrect for profile %s.\nExpected: %s\nGot: %s", value, name, correct, got[value])
+			}
+		}
+	}
+}
+
+// SHA_Interleave must drop leading zeros, and then one more byte if
+// an odd number remain.
+func TestSHAInterleave(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC2945)
+
+	vectors := map[string]string{
+		"0000ABCDEF0123456789": "40088D06C97ADB15DC6583DEFB0F6CC23A48E79723CDB10D8EF591C3179140001AA42F2B6A9D8C75",
+		"00ABCDEF01234567":     "C3F3A771B89D93ADEA8A0D52D391212131C2BD921D5216F2DB3BAF679C7E941339DA44F8DA472B38",
+	}
+
+	for input, correct := range vectors {
+		var s big.Int
+		s.SetString(input, 16)
+
+		if k := fmt.Sprintf("%X", config.sha_interleave(Pad(len(input)/2, s.Bytes()))); k != correct {
+			t.Errorf("Error: SHA_Interleave(%s) incorrect.\nExpected: %s\nGot: %s", input, correct, k)
+		}
+	}
+
+	if _, err := GetProfile("no-such-profile"); err == nil {
+		t.Error("Error: unknown profile name accepted.")
+	}
+}
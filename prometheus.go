@@ -0,0 +1,210 @@
+package libgosrp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics kept in memory and served over HTTP in the Prometheus text
+// format, for mounting on a path such as /metrics:
+//
+//	srp_handshakes_started_total{group}            counter
+//	srp_handshakes_completed_total{group}          counter
+//	srp_handshakes_failed_total{group,reason}      counter
+//	srp_exp_duration_seconds{group}                histogram
+//	srp_kdf_duration_seconds{group}                histogram
+//	srp_pending_sessions                           gauge
+//	srp_lockouts_total{kind}                       counter
+//
+// Safe for concurrent use.
+type PrometheusMetrics struct {
+	mu        sync.Mutex
+	started   *promFamily
+	completed *promFamily
+	failed    *promFamily
+	exp       *promFamily
+	kdf       *promFamily
+	pending   *promFamily
+	lockouts  *promFamily
+}
+
+// One metric and its series, by label set.
+type promFamily struct {
+	name    string
+	help    string
+	kind    string
+	buckets []float64 //upper bounds, for histograms
+	series  map[string]*promSeries
+}
+
+type promSeries struct {
+	value  float64
+	counts []uint64 //per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		started:   newPromFamily("srp_handshakes_started_total", "Handshakes for which a challenge was sent.", "counter", nil),
+		completed: newPromFamily("srp_handshakes_completed_total", "Handshakes whose client proof was accepted.", "counter", nil),
+		failed:    newPromFamily("srp_handshakes_failed_total", "Handshakes that failed, by reason.", "counter", nil),
+		exp: newPromFamily("srp_exp_duration_seconds", "Time taken by modular exponentiations.", "histogram",
+			[]float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1}),
+		kdf: newPromFamily("srp_kdf_duration_seconds", "Time taken to derive x from a password.", "histogram",
+			[]float64{0.00001, 0.0001, 0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5}),
+		pending:  newPromFamily("srp_pending_sessions", "Handshakes waiting for a client proof.", "gauge", nil),
+		lockouts: newPromFamily("srp_lockouts_total", "Users and addresses locked out, by kind.", "counter", nil),
+	}
+}
+
+func newPromFamily(name, help, kind string, buckets []float64) *promFamily {
+	return &promFamily{name, help, kind, buckets, make(map[string]*promSeries)}
+}
+
+func (p *PrometheusMetrics) HandshakeStarted(group string) {
+	p.add(p.started, 1, "group", group)
+}
+
+func (p *PrometheusMetrics) HandshakeCompleted(group string) {
+	p.add(p.completed, 1, "group", group)
+}
+
+func (p *PrometheusMetrics) HandshakeFailed(group, reason string) {
+	p.add(p.failed, 1, "group", group, "reason", reason)
+}
+
+func (p *PrometheusMetrics) Exp(group string, d time.Duration) {
+	p.observe(p.exp, d, "group", group)
+}
+
+func (p *PrometheusMetrics) KDF(group string, d time.Duration) {
+	p.observe(p.kdf, d, "group", group)
+}
+
+func (p *PrometheusMetrics) PendingSessions(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending.get().value = float64(n)
+}
+
+func (p *PrometheusMetrics) Lockout(kind string) {
+	p.add(p.lockouts, 1, "kind", kind)
+}
+
+// Serves the metrics in the Prometheus text format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var out bytes.Buffer
+	p.write(&out)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(out.Bytes())
+}
+
+func (p *PrometheusMetrics) add(f *promFamily, delta float64, labels ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f.get(labels...).value += delta
+}
+
+func (p *PrometheusMetrics) observe(f *promFamily, d time.Duration, labels ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := f.get(labels...)
+	seconds := d.Seconds()
+	for i, bound := range f.buckets {
+		if seconds <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+
+	s.sum += seconds
+	s.count++
+}
+
+// Returns the series for labels, given as names and values in turn,
+// creating it if needed.
+func (f *promFamily) get(labels ...string) *promSeries {
+	var key strings.Builder
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			key.WriteByte(',')
+		}
+		fmt.Fprintf(&key, "%s=\"%s\"", labels[i], promEscape(labels[i+1]))
+	}
+
+	s, ok := f.series[key.String()]
+	if !ok {
+		s = &promSeries{counts: make([]uint64, len(f.buckets))}
+		f.series[key.String()] = s
+	}
+
+	return s
+}
+
+func (p *PrometheusMetrics) write(out *bytes.Buffer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, f := range []*promFamily{p.started, p.completed, p.failed, p.exp, p.kdf, p.pending, p.lockouts} {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != "histogram" {
+				fmt.Fprintf(out, "%s%s %s\n", f.name, promLabels(key, ""), promFloat(s.value))
+				continue
+			}
+
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(out, "%s_bucket%s %d\n", f.name, promLabels(key, promFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(out, "%s_bucket%s %d\n", f.name, promLabels(key, "+Inf"), s.count)
+			fmt.Fprintf(out, "%s_sum%s %s\n", f.name, promLabels(key, ""), promFloat(s.sum))
+			fmt.Fprintf(out, "%s_count%s %d\n", f.name, promLabels(key, ""), s.count)
+		}
+	}
+}
+
+// Returns the label set key, with le added if it isn't empty, in
+// braces, or nothing for no labels.
+func promLabels(key, le string) string {
+	if le != "" {
+		if key != "" {
+			key += ","
+		}
+		key += "le=\"" + le + "\""
+	}
+
+	if key == "" {
+		return ""
+	}
+
+	return "{" + key + "}"
+}
+
+func promFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func promEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,61 @@
+package libgosrp
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	server, config := testserver(t)
+	server.config.SetMetrics(metrics)
+
+	throttle := NewThrottle(NewMemoryCounter())
+	throttle.User.LockoutAfter = 1
+	server.SetThrottle(throttle)
+
+	testattempt(server, config, "192.0.2.1", "alice", "password123")
+	testattempt(server, config, "192.0.2.1", "nobody", "password123")
+	testattempt(server, config, "192.0.2.1", "alice", "wrong")
+	testattempt(server, config, "192.0.2.1", "alice", "password123")
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+
+	group := config.GroupID()
+	for _, line := range []string{
+		`# TYPE srp_handshakes_started_total counter`,
+		`srp_handshakes_started_total{group="` + group + `"} 3`,
+		`srp_handshakes_completed_total{group="` + group + `"} 1`,
+		`srp_handshakes_failed_total{group="` + group + `",reason="bad_proof"} 1`,
+		`srp_handshakes_failed_total{group="` + group + `",reason="throttled"} 1`,
+		`srp_handshakes_failed_total{group="` + group + `",reason="unknown_user"} 1`,
+		`# TYPE srp_exp_duration_seconds histogram`,
+		`# TYPE srp_pending_sessions gauge`,
+		`srp_pending_sessions 0`,
+		`srp_lockouts_total{kind="user"} 2`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("Error: metrics missing %q.", line)
+		}
+	}
+
+	//the client shares the config, so its work is counted too
+	for _, name := range []string{"srp_exp_duration_seconds", "srp_kdf_duration_seconds"} {
+		if !strings.Contains(string(body), name+`_bucket{group="`+group+`",le="+Inf"} `) ||
+			!strings.Contains(string(body), name+`_count{group="`+group+`"} `) {
+			t.Errorf("Error: metrics missing histogram %s.", name)
+		}
+	}
+
+	if recorder.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Error("Error: metrics served with the wrong content type.")
+	}
+
+	if t.Failed() {
+		t.Log(string(body))
+	}
+}
@@ -0,0 +1,167 @@
+package libgosrp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Protocol buffer wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// Messages in the protocol buffer wire format, following the schema
+// in srp.proto. Fields are written in field number order, leaving out
+// empty strings and zero versions as proto3 does. As usual for
+// protocol buffers, unknown fields are skipped when decoding, but
+// known fields must have the right wire type and, other than lists,
+// may not repeat, and every bytes field that isn't optional must be
+// present.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (ProtobufCodec) Marshal(m interface{}) ([]byte, error) {
+	_, fields, err := messageFields(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	for _, f := range fields {
+		b, err := f.encode()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case f.version != nil:
+			if b[0] != 0 {
+				out = binary.AppendUvarint(out, uint64(f.number)<<3|protoVarint)
+				out = binary.AppendUvarint(out, uint64(b[0]))
+			}
+		case f.list != nil:
+			list, _ := readlist(b)
+			for _, s := range list {
+				out = protoAppendBytes(out, f.number, []byte(s))
+			}
+		case len(b) > 0:
+			out = protoAppendBytes(out, f.number, b)
+		}
+	}
+
+	return out, nil
+}
+
+func protoAppendBytes(out []byte, number int, b []byte) []byte {
+	out = binary.AppendUvarint(out, uint64(number)<<3|protoBytes)
+	out = binary.AppendUvarint(out, uint64(len(b)))
+	return append(out, b...)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, m interface{}) error {
+	_, fields, err := messageFields(m)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]*messageField)
+	for i := range fields {
+		known[fields[i].number] = &fields[i]
+	}
+
+	values := make(map[int][]byte)
+
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrorMalformed("bad protobuf tag")
+		}
+		data = data[n:]
+
+		number, wiretype := int(tag>>3), tag&7
+
+		f := known[number]
+		if f != nil && (wiretype == protoVarint) != (f.version != nil) {
+			return ErrorMalformed(fmt.Sprintf("wrong protobuf wire type for %s", f.name))
+		}
+
+		var value []byte
+		switch wiretype {
+		case protoVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return ErrorMalformed("bad protobuf varint")
+			}
+			data = data[n:]
+
+			if f == nil {
+				continue
+			} else if v > 0xff {
+				return ErrorMalformed(fmt.Sprintf("%s must be 0 to 255", f.name))
+			}
+			value = []byte{byte(v)}
+		case protoFixed64, protoFixed32:
+			size := 8
+			if wiretype == protoFixed32 {
+				size = 4
+			}
+
+			if len(data) < size {
+				return ErrorMalformed("truncated protobuf")
+			}
+			data = data[size:]
+			continue
+		case protoBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > maxValueLen {
+				return ErrorMalformed(fmt.Sprintf("protobuf field exceeds the limit of %d bytes", maxValueLen))
+			} else if uint64(len(data)-n) < length {
+				return ErrorMalformed("truncated protobuf")
+			}
+
+			value = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return ErrorMalformed(fmt.Sprintf("unsupported protobuf wire type %d", wiretype))
+		}
+
+		if f == nil {
+			continue
+		} else if f.list != nil {
+			//each string of a list is a field of its own
+			w := wireWriter(values[number])
+			w.write(value)
+			if len(w) > maxValueLen {
+				return ErrorMalformed(fmt.Sprintf("%s must be at most %d bytes", f.name, maxValueLen))
+			}
+
+			values[number] = w
+			continue
+		}
+
+		if _, ok := values[number]; ok {
+			return ErrorMalformed(fmt.Sprintf("repeated protobuf field %d", number))
+		}
+		values[number] = value
+	}
+
+	ordered := make([][]byte, len(fields))
+	for i, f := range fields {
+		value, ok := values[f.number]
+		if !ok && f.version != nil {
+			value = []byte{0}
+		} else if !ok && f.bytes != nil && !f.optional {
+			return ErrorMalformed(fmt.Sprintf("missing field %s", f.name))
+		}
+
+		ordered[i] = value
+	}
+
+	return setFields(fields, ordered)
+}
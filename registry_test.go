@@ -0,0 +1,116 @@
+package libgosrp
+
+import (
+	"context"
+	"testing"
+)
+
+// Every built in group must be a safe prime with a usable generator.
+func TestBuiltinGroups(t *testing.T) {
+	for _, b := range builtinGroups {
+		gp, err := LookupGroup(b.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name := gp.Name(); name != b.name {
+			t.Errorf("Error: group %s found under the name %q.", b.name, name)
+		}
+
+		if testing.Short() && gp.N.BitLen() > 4096 {
+			continue
+		}
+
+		if err = gp.check_safe_prime(1); err != nil {
+			t.Errorf("Error: group %s: %v", b.name, err)
+		}
+	}
+}
+
+// Verifiers made before their group is registered still match it.
+func TestRegisterGroupLater(t *testing.T) {
+	ctx := context.Background()
+
+	var gp SRPGroupParameters
+	gp.N.SetString(primeRFC5054_2048, 16)
+	gp.G.SetInt64(5)
+
+	config, _ := GetProfile(ProfileRFC5054)
+	config.SetGroup(gp)
+
+	var v Verifier
+	if _, err := v.New("alice", "password123", 16, config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterGroup("test-2048-g5", gp); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Check(config); err != nil {
+		t.Error("Error: verifier rejected once its group was registered:", err)
+	}
+
+	//records that kept the name still match
+	named := v
+	named.Group = "test-2048-g5"
+	if err := named.Check(config); err != nil {
+		t.Error("Error: verifier naming its group rejected:", err)
+	}
+
+	store := NewMemoryStore()
+	store.Put(ctx, v)
+	server, _ := NewServer(config, store)
+
+	client, _ := GetProfile(ProfileRFC5054)
+	client.SetGroup(gp)
+	if _, _, err := testlogin(t, server, client, "alice", "password123"); err != nil {
+		t.Error("Error: login failed once the group was registered:", err)
+	}
+}
+
+func TestRegisterGroup(t *testing.T) {
+	var gp SRPGroupParameters
+	gp.N.SetInt64(23)
+	gp.G.SetInt64(5)
+
+	if err := RegisterGroup("test-23", gp); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterGroup("test-23-again", gp); err == nil {
+		t.Error("Error: registered the same group twice.")
+	}
+
+	name, found, err := LookupGroupByFingerprint(gp.Fingerprint())
+	if err != nil {
+		t.Fatal(err)
+	} else if name != "test-23" || !found.Equal(gp) {
+		t.Errorf("Error: fingerprint lookup gave %s.", name)
+	}
+
+	if gp.IsKnown() {
+		t.Error("Error: registered group treated as built in.")
+	}
+
+	//same N as rfc5054-3072, different g
+	modp, _ := LookupGroup("modp3072")
+	rfc, _ := GetGroupParameters(3072)
+	if modp.Fingerprint() == rfc.Fingerprint() {
+		t.Error("Error: groups differing only in g share a fingerprint.")
+	}
+
+	//GetGroupParameters(4095) must not find a registered group
+	var other SRPGroupParameters
+	other.N.SetInt64(47)
+	other.G.SetInt64(5)
+	for _, reserved := range []string{"rfc5054-4095", "modp1024", "ffdhe1024", "wow2"} {
+		if err = RegisterGroup(reserved, other); err == nil {
+			t.Errorf("Error: registered a group as %s.", reserved)
+		}
+	}
+
+	if _, err = LookupGroup("no-such-group"); err == nil {
+		t.Error("Error: found a group that was never registered.")
+	}
+}
@@ -0,0 +1,456 @@
+package libgosrp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults for Server.
+const (
+	DefaultSessionTimeout = time.Minute
+	DefaultMaxPending     = 10000
+)
+
+// Runs the server side of many handshakes at once: looks users up in
+// a VerifierStore, and keeps each session between the client's hello
+// and its proof. Safe for concurrent use.
+//
+// Unknown users get a challenge like any other, with a salt that
+// stays the same between attempts, and then fail at the proof, so
+// the server doesn't reveal which users exist.
+//
+// When config has a Pepper, verifiers that aren't sealed with the
+// current pepper key are sealed with it after the user's next
+// successful login.
+type Server struct {
+	store       VerifierStore
+	config      *SRPConfig
+	timeout     time.Duration
+	max_pending int
+	decoy_key   []byte //derives salts for unknown users
+	throttle    *Throttle
+	observer    Observer
+
+	mu      sync.Mutex
+	configs map[string]*SRPConfig //by profile and group
+	pending map[string]*pendingSession
+	swept   time.Time
+}
+
+type pendingSession struct {
+	session *SRPSession
+	started time.Time
+	expires time.Time
+	//verifier to store again once the login succeeds
+	upgrade *Verifier
+	//details for the session's events
+	event Event
+}
+
+// Outcome of a successful login.
+type Login struct {
+	I          string
+	SessionKey []byte
+	Proof      ServerProof
+}
+
+type ErrorServerBusy int
+
+func (e ErrorServerBusy) Error() string {
+	return fmt.Sprintf("Too many logins in progress: the limit is %d.", int(e))
+}
+
+// Returns a server using store. Verifiers made from a named profile
+// are used with that profile and their own group; config is used for
+// the rest, and for unknown users.
+func NewServer(config *SRPConfig, store VerifierStore) (*Server, error) {
+	if err := config.check_init(); err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		store:       store,
+		config:      config,
+		timeout:     DefaultSessionTimeout,
+		max_pending: DefaultMaxPending,
+		decoy_key:   key,
+		configs:     make(map[string]*SRPConfig),
+		pending:     make(map[string]*pendingSession),
+	}, nil
+}
+
+// Sets how long a client has between its hello and its proof.
+func (s *Server) SetSessionTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// Sets the most handshakes that may wait for a proof at once. Further
+// hellos get ErrorServerBusy.
+func (s *Server) SetMaxPending(n int) {
+	s.max_pending = n
+}
+
+// Sets the throttle that slows down repeated failed logins, by user
+// and by the client address given to WithRemoteAddr.
+func (s *Server) SetThrottle(t *Throttle) {
+	s.throttle = t
+}
+
+// Sets the observer told of each stage of every login.
+func (s *Server) SetObserver(o Observer) {
+	s.observer = o
+}
+
+// Starts a login. Returns an id for the session, to be handed to
+// Proof with the client's proof, and the challenge to send back.
+func (s *Server) Hello(ctx context.Context, hello ClientHello) (string, ServerChallenge, error) {
+	start := time.Now()
+	event := Event{User: hello.I, Addr: RemoteAddr(ctx)}
+
+	if s.throttle != nil {
+		if err := s.throttle.check(ctx, hello.I); err != nil {
+			s.observe(ctx, event, EventThrottled, start, err)
+			return "", ServerChallenge{}, err
+		}
+	}
+
+	v, config, known, err := s.lookup(ctx, hello.I)
+	if err != nil {
+		return "", ServerChallenge{}, err
+	}
+
+	event.Profile, event.Group = config.Profile(), config.GroupID()
+	event.UnknownUser = !known
+
+	//stops computing B and S if the client goes away
+	session, err := new(SRPSession).NewContext(ctx, v, config)
+	if err != nil {
+		return "", ServerChallenge{}, err
+	}
+
+	if err = session.ReadHelloContext(ctx, hello); err != nil {
+		if _, illegal := err.(ErrorIllegalParameter); illegal {
+			s.observe(ctx, event, EventInvalidA, start, err)
+		}
+
+		return "", ServerChallenge{}, err
+	}
+
+	challenge, err := session.Challenge()
+	if err != nil {
+		session.Close()
+		return "", ServerChallenge{}, err
+	}
+
+	pending := &pendingSession{session: session, started: start, event: event}
+	if pepper := config.pepper; known && pepper != nil && v.Pepper != pepper.Current() {
+		pending.upgrade = &v
+	}
+
+	id, expired, err := s.add(pending)
+	s.observe_expired(expired)
+	if err != nil {
+		if m := s.config.metrics; m != nil {
+			m.HandshakeFailed(event.Group, NewErrorMessage(err).Code)
+		}
+
+		session.Close()
+		return "", ServerChallenge{}, err
+	}
+
+	if known {
+		s.observe(ctx, event, EventChallenge, start, nil)
+	} else {
+		s.observe(ctx, event, EventUnknownUser, start, nil)
+	}
+
+	return id, challenge, nil
+}
+
+// Checks the client's proof for the session id. Each session gets one
+// attempt, whether or not it succeeds.
+func (s *Server) Proof(ctx context.Context, id string, p ClientProof) (Login, error) {
+	start := time.Now()
+	pending, expired := s.take(id)
+	if expired != nil {
+		s.observe_expired([]*pendingSession{expired})
+	}
+
+	if pending == nil {
+		return Login{}, ErrorSessionState("unknown or expired session")
+	}
+	session := pending.session
+	defer session.Close()
+
+	if m := s.config.metrics; m != nil {
+		defer func() { m.PendingSessions(s.Pending()) }()
+	}
+
+	//the proof may come from elsewhere than the hello
+	event := pending.event
+	event.Addr = RemoteAddr(ctx)
+
+	//checked again, for proofs of sessions started before the limit
+	//was reached. The attempt is counted as a failure before the proof
+	//is checked, so that proofs sent at once can't all get in under
+	//the limit.
+	var reserved *reservation
+	if s.throttle != nil {
+		var err error
+		if reserved, err = s.throttle.reserve(ctx, session.i); err != nil {
+			s.observe(ctx, event, EventThrottled, start, err)
+			return Login{}, err
+		}
+	}
+
+	if err := session.ReadProof(p); err != nil {
+		s.observe(ctx, event, EventProofFailed, start, err)
+
+		//only a wrong proof keeps the failure it reserved
+		if _, bad := err.(ErrorBadProof); bad && reserved != nil {
+			lockouts := s.throttle.fail(ctx, reserved)
+			if m := s.config.metrics; m != nil {
+				for _, l := range lockouts {
+					if l.User != "" {
+						m.Lockout("user")
+					} else {
+						m.Lockout("addr")
+					}
+				}
+			}
+		} else if reserved != nil {
+			if terr := s.throttle.refund(ctx, reserved); terr != nil {
+				return Login{}, terr
+			}
+		}
+
+		return Login{}, err
+	}
+
+	if reserved != nil {
+		if err := s.throttle.succeed(ctx, reserved); err != nil {
+			return Login{}, err
+		}
+	}
+
+	proof, err := session.Proof()
+	if err != nil {
+		return Login{}, err
+	}
+	s.observe(ctx, event, EventSuccess, start, nil)
+
+	if pending.upgrade != nil {
+		//on failure, the upgrade is tried again at the next login
+		upgraded := time.Now()
+		err = s.upgrade(ctx, pending.upgrade, session.config)
+		s.observe(ctx, event, EventVerifierUpgraded, upgraded, err)
+	}
+
+	return Login{session.i, session.SessionKey(), proof}, nil
+}
+
+// A VerifierStore that can store a verifier only if it hasn't changed
+// since it was read, as SQLStore can.
+type revisionStore interface {
+	GetRevision(ctx context.Context, i string) (Verifier, int64, error)
+	PutRevision(ctx context.Context, v Verifier, revision int64) (int64, error)
+}
+
+// Seals v, the verifier the login used, with the current pepper key
+// and stores it, unless the stored verifier has changed since the
+// hello, as when the password is changed during the login; then it
+// returns ErrorConflict. With a revisionStore the check and the write
+// are atomic; with other stores a change between them can still be
+// overwritten.
+func (s *Server) upgrade(ctx context.Context, v *Verifier, config *SRPConfig) error {
+	var current Verifier
+	var revision int64
+	var err error
+
+	revisions, atomic := s.store.(revisionStore)
+	if atomic {
+		current, revision, err = revisions.GetRevision(ctx, v.I)
+	} else {
+		current, err = s.store.Get(ctx, v.I)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if current.Pepper != v.Pepper || current.Salt.Cmp(&v.Salt) != 0 || current.Verifier.Cmp(&v.Verifier) != 0 {
+		return ErrorConflict(v.I)
+	}
+
+	if err = config.pepper.Seal(&current, config); err != nil {
+		return err
+	}
+
+	if atomic {
+		_, err = revisions.PutRevision(ctx, current, revision)
+		return err
+	}
+
+	return s.store.Put(ctx, current)
+}
+
+// Number of handshakes waiting for a proof.
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending)
+}
+
+// Ends every pending handshake.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, p := range s.pending {
+		p.session.Close()
+		delete(s.pending, id)
+	}
+
+	return nil
+}
+
+// Returns the verifier and config for user i, or a decoy for unknown
+// users, and whether the user exists.
+func (s *Server) lookup(ctx context.Context, i string) (Verifier, *SRPConfig, bool, error) {
+	v, err := s.store.Get(ctx, i)
+	if _, unknown := err.(ErrorUnknownUser); unknown {
+		return s.decoy(i), s.config, false, nil
+	} else if err != nil {
+		return Verifier{}, nil, false, err
+	}
+
+	config, err := s.config_for(&v)
+	return v, config, true, err
+}
+
+// Returns a verifier for an unknown user: the salt is derived from
+// the username, so it's the same on every attempt, as a real user's
+// would be, and the verifier is random.
+func (s *Server) decoy(i string) Verifier {
+	mac := hmac.New(sha256.New, s.decoy_key)
+	mac.Write([]byte(i))
+
+	var v Verifier
+	v.I = i
+	v.Salt.SetBytes(mac.Sum(nil)[:16])
+
+	random, _ := RandomBytes(uint(s.config.nlen()))
+	v.Verifier.Mod(&random, &s.config.gp.N)
+	v.Group = s.config.gp.Fingerprint()
+	v.Profile = s.config.verifier_profile()
+	v.Version = VerifierVersion
+	return v
+}
+
+func (s *Server) config_for(v *Verifier) (*SRPConfig, error) {
+	if v.Version == 0 || (v.Profile == s.config.verifier_profile() && s.config.gp.identified_by(v.Group)) {
+		return s.config, nil
+	}
+
+	key := v.Profile + "/" + v.Group
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if config, ok := s.configs[key]; ok {
+		return config, nil
+	}
+
+	config, err := v.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	config.SetPrecompute(s.config.precompute)
+	config.SetPepper(s.config.pepper)
+	config.SetMetrics(s.config.metrics)
+	s.configs[key] = config
+	return config, nil
+}
+
+// Adds p to the pending sessions, returning its id and any sessions
+// that expired meanwhile.
+func (s *Server) add(p *pendingSession) (string, []*pendingSession, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []*pendingSession
+	now := time.Now()
+	if now.Sub(s.swept) >= time.Second || len(s.pending) >= s.max_pending {
+		expired = s.sweep(now)
+	}
+
+	if len(s.pending) >= s.max_pending {
+		return "", expired, ErrorServerBusy(s.max_pending)
+	}
+
+	p.expires = now.Add(s.timeout)
+	s.pending[id] = p
+	return id, expired, nil
+}
+
+// Removes the session id from the pending sessions and returns it, or
+// nil if there is no such session or it has expired. An expired
+// session is returned second.
+func (s *Server) take(id string) (*pendingSession, *pendingSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[id]
+	if !ok {
+		return nil, nil
+	}
+
+	delete(s.pending, id)
+	if time.Now().After(p.expires) {
+		p.session.Close()
+		return nil, p
+	}
+
+	return p, nil
+}
+
+// Tells the observer about expired sessions, outside s.mu.
+func (s *Server) observe_expired(expired []*pendingSession) {
+	for _, p := range expired {
+		s.observe(context.Background(), p.event, EventExpired, p.started, ErrorSessionState("session expired"))
+	}
+}
+
+// Closes expired sessions and returns them. s.mu must be held.
+func (s *Server) sweep(now time.Time) []*pendingSession {
+	var expired []*pendingSession
+	for id, p := range s.pending {
+		if now.After(p.expires) {
+			p.session.Close()
+			delete(s.pending, id)
+			expired = append(expired, p)
+		}
+	}
+
+	s.swept = now
+	return expired
+}
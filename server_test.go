@@ -0,0 +1,151 @@
+package libgosrp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testserver(t *testing.T) (*Server, *SRPConfig) {
+	config, _ := GetProfile(ProfileRFC5054)
+
+	store := NewMemoryStore()
+	var v Verifier
+	if _, err := v.New("alice", "password123", 16, config); err != nil {
+		t.Fatal(err)
+	}
+	store.Put(context.Background(), v)
+
+	server, err := NewServer(config, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, config
+}
+
+// Logs in to server, returning the client session and the error from
+// the server's Proof.
+func testlogin(t *testing.T, server *Server, config *SRPConfig, i, p string) (*SRPClientSession, Login, error) {
+	ctx := context.Background()
+
+	csess, err := new(SRPClientSession).New(i, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello, _ := csess.Hello()
+	id, challenge, err := server.Hello(ctx, hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = csess.ReadChallenge(challenge, p); err != nil {
+		t.Fatal(err)
+	}
+
+	proof, _ := csess.Proof()
+	login, err := server.Proof(ctx, id, proof)
+	return csess, login, err
+}
+
+func TestServer(t *testing.T) {
+	server, config := testserver(t)
+
+	csess, login, err := testlogin(t, server, config, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = csess.ReadProof(login.Proof); err != nil {
+		t.Error(err)
+	}
+
+	if login.I != "alice" || !bytes.Equal(login.SessionKey, csess.SessionKey()) {
+		t.Error("Error: login result incorrect.")
+	}
+
+	if _, _, err = testlogin(t, server, config, "alice", "password124"); err == nil {
+		t.Error("Error: server accepted a wrong password.")
+	}
+
+	if server.Pending() != 0 {
+		t.Error("Error: finished sessions still pending.")
+	}
+}
+
+// Unknown users must look like known ones until the proof fails.
+func TestServerUnknownUser(t *testing.T) {
+	server, config := testserver(t)
+
+	var salts [2][]byte
+	for j := range salts {
+		csess, _ := new(SRPClientSession).New("mallory", config)
+		hello, _ := csess.Hello()
+
+		id, challenge, err := server.Hello(context.Background(), hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		salts[j] = challenge.Salt
+
+		csess.ReadChallenge(challenge, "guess")
+		proof, _ := csess.Proof()
+		if _, err = server.Proof(context.Background(), id, proof); err == nil {
+			t.Fatal("Error: unknown user logged in.")
+		} else if _, ok := err.(ErrorBadProof); !ok {
+			t.Errorf("Error: unknown user failed differently from a wrong password: %v", err)
+		}
+	}
+
+	if !bytes.Equal(salts[0], salts[1]) || len(salts[0]) != 16 {
+		t.Error("Error: unknown user's salt is not stable.")
+	}
+}
+
+func TestServerExpiry(t *testing.T) {
+	server, config := testserver(t)
+	server.SetSessionTimeout(time.Nanosecond)
+
+	if _, _, err := testlogin(t, server, config, "alice", "password123"); err == nil {
+		t.Error("Error: expired session accepted a proof.")
+	}
+
+	server.SetSessionTimeout(time.Minute)
+	server.SetMaxPending(1)
+
+	csess, _ := new(SRPClientSession).New("alice", config)
+	hello, _ := csess.Hello()
+	if _, _, err := server.Hello(context.Background(), hello); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := server.Hello(context.Background(), hello); err == nil {
+		t.Error("Error: server exceeded its pending session limit.")
+	}
+
+	server.Close()
+	if server.Pending() != 0 {
+		t.Error("Error: Close left sessions pending.")
+	}
+}
+
+func TestServerCancel(t *testing.T) {
+	server, config := testserver(t)
+
+	csess, _ := new(SRPClientSession).New("alice", config)
+	hello, _ := csess.Hello()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := server.Hello(ctx, hello); !errors.Is(err, context.Canceled) {
+		t.Error("Error: server answered a hello whose context was cancelled: ", err)
+	}
+
+	if server.Pending() != 0 {
+		t.Error("Error: cancelled hello left a pending session.")
+	}
+}
@@ -0,0 +1,109 @@
+package libgosrp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// A ThrottleCounter in a SQL database, so that servers sharing the
+// database share failure counts. Counts are kept in this table,
+// created by the same migrations as SQLStore's:
+//
+//	CREATE TABLE srp_failures (
+//		name         TEXT PRIMARY KEY,     -- "user:" or "addr:" and the username or address
+//		count        INTEGER NOT NULL,
+//		last_failure TIMESTAMPTZ NOT NULL,
+//		previous_failure TIMESTAMPTZ       -- the failure before last_failure, or NULL
+//	)
+//
+// Rows whose last failure is old enough not to count any more can be
+// deleted at any time.
+type SQLCounter struct {
+	get        *sql.Stmt
+	add        *sql.Stmt
+	refund     *sql.Stmt
+	reset      *sql.Stmt
+	statements []*sql.Stmt
+}
+
+var sqlCounterStatements = map[string]string{
+	"get": "SELECT count, last_failure FROM srp_failures WHERE name = $1",
+	"add": "INSERT INTO srp_failures (name, count, last_failure) VALUES ($1, 1, $2) " +
+		"ON CONFLICT (name) DO UPDATE SET last_failure = excluded.last_failure, " +
+		"previous_failure = CASE WHEN srp_failures.last_failure < $3 THEN NULL ELSE srp_failures.last_failure END, " +
+		"count = CASE WHEN srp_failures.last_failure < $3 THEN 1 ELSE srp_failures.count + 1 END " +
+		"RETURNING count, last_failure, previous_failure",
+	"refund": "UPDATE srp_failures SET count = count - 1, " +
+		"last_failure = CASE WHEN last_failure = $2 THEN COALESCE(previous_failure, last_failure) ELSE last_failure END, " +
+		"previous_failure = CASE WHEN last_failure = $2 THEN NULL ELSE previous_failure END " +
+		"WHERE name = $1 AND count > 0",
+	"reset": "DELETE FROM srp_failures WHERE name = $1",
+}
+
+// Returns a counter using db, which speaks dialect, after creating or
+// updating the tables it needs. The counter doesn't close db.
+func OpenSQLCounter(ctx context.Context, db *sql.DB, dialect string) (*SQLCounter, error) {
+	replacer, err := sqlDialect(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = migrateSQL(ctx, db, replacer); err != nil {
+		return nil, err
+	}
+
+	c := new(SQLCounter)
+	for name, stmt := range map[string]**sql.Stmt{"get": &c.get, "add": &c.add, "refund": &c.refund, "reset": &c.reset} {
+		if *stmt, err = db.PrepareContext(ctx, replacer.Replace(sqlCounterStatements[name])); err != nil {
+			c.Close()
+			return nil, err
+		}
+
+		c.statements = append(c.statements, *stmt)
+	}
+
+	return c, nil
+}
+
+func (c *SQLCounter) Get(ctx context.Context, key string) (Failures, error) {
+	var f Failures
+	err := c.get.QueryRowContext(ctx, key).Scan(&f.Count, &f.Last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Failures{}, nil
+	}
+
+	return f, err
+}
+
+func (c *SQLCounter) Add(ctx context.Context, key string, at, since time.Time) (Failures, error) {
+	var f Failures
+	var previous sql.NullTime
+	err := c.add.QueryRowContext(ctx, key, at.UTC(), since.UTC()).Scan(&f.Count, &f.Last, &previous)
+	f.Previous = previous.Time
+	return f, err
+}
+
+func (c *SQLCounter) Refund(ctx context.Context, key string, at time.Time) error {
+	_, err := c.refund.ExecContext(ctx, key, at.UTC())
+	return err
+}
+
+func (c *SQLCounter) Reset(ctx context.Context, key string) error {
+	_, err := c.reset.ExecContext(ctx, key)
+	return err
+}
+
+// Releases the prepared statements. The database is left open.
+func (c *SQLCounter) Close() error {
+	var err error
+	for _, stmt := range c.statements {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	c.statements = nil
+	return err
+}
@@ -0,0 +1,308 @@
+package libgosrp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SQL dialects understood by SQLStore.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// A VerifierStore in a SQL database, used through database/sql with
+// whatever driver the program registers. Verifiers are kept in this
+// table, shown as created for PostgreSQL (SQLite uses BLOB and
+// TIMESTAMP for the binary and time columns):
+//
+//	CREATE TABLE srp_verifiers (
+//		username   TEXT PRIMARY KEY,
+//		salt       BYTEA NOT NULL,       -- big-endian, as Verifier.Salt.Bytes()
+//		verifier   BYTEA NOT NULL,       -- big-endian, as Verifier.Verifier.Bytes()
+//		group_id   TEXT NOT NULL,        -- Verifier.Group
+//		profile    TEXT NOT NULL,        -- Verifier.Profile
+//		version    INTEGER NOT NULL,     -- Verifier.Version
+//		revision   BIGINT NOT NULL,      -- 1 when inserted, incremented by every update
+//		created_at TIMESTAMPTZ NOT NULL, -- Verifier.Created
+//		updated_at TIMESTAMPTZ NOT NULL, -- time of the last insert or update
+//		pepper     TEXT NOT NULL         -- Verifier.Pepper
+//	)
+//
+// The schema version is kept in srp_schema_version, and
+// OpenSQLStore brings older schemas up to date. The revision column
+// lets GetRevision and PutRevision update a verifier without losing
+// changes made concurrently by another process. Safe for concurrent
+// use.
+type SQLStore struct {
+	db         *sql.DB
+	get        *sql.Stmt
+	put        *sql.Stmt
+	insert     *sql.Stmt
+	update     *sql.Stmt
+	delete     *sql.Stmt
+	list       *sql.Stmt
+	statements []*sql.Stmt
+}
+
+// Schema changes, in order: entry i takes the schema from version i
+// to version i+1. Written for PostgreSQL; sqlDialect adjusts them for
+// the others.
+var sqlMigrations = []string{
+	`CREATE TABLE srp_verifiers (
+		username   TEXT PRIMARY KEY,
+		salt       BYTEA NOT NULL,
+		verifier   BYTEA NOT NULL,
+		group_id   TEXT NOT NULL,
+		profile    TEXT NOT NULL,
+		version    INTEGER NOT NULL,
+		revision   BIGINT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	)`,
+	`ALTER TABLE srp_verifiers ADD COLUMN pepper TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE srp_failures (
+		name         TEXT PRIMARY KEY,
+		count        INTEGER NOT NULL,
+		last_failure TIMESTAMPTZ NOT NULL
+	)`,
+	`ALTER TABLE srp_failures ADD COLUMN previous_failure TIMESTAMPTZ`,
+}
+
+const sqlColumns = "username, salt, verifier, group_id, profile, version, created_at, updated_at, pepper, revision"
+
+var sqlStatements = map[string]string{
+	"get": "SELECT " + sqlColumns + " FROM srp_verifiers WHERE username = $1",
+	"put": "INSERT INTO srp_verifiers (" + sqlColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1) " +
+		"ON CONFLICT (username) DO UPDATE SET salt = excluded.salt, verifier = excluded.verifier, " +
+		"group_id = excluded.group_id, profile = excluded.profile, version = excluded.version, " +
+		"created_at = excluded.created_at, updated_at = excluded.updated_at, pepper = excluded.pepper, revision = srp_verifiers.revision + 1",
+	"insert": "INSERT INTO srp_verifiers (" + sqlColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1) " +
+		"ON CONFLICT (username) DO NOTHING",
+	"update": "UPDATE srp_verifiers SET salt = $2, verifier = $3, group_id = $4, profile = $5, version = $6, " +
+		"created_at = $7, updated_at = $8, pepper = $9, revision = revision + 1 WHERE username = $1 AND revision = $10",
+	"delete": "DELETE FROM srp_verifiers WHERE username = $1",
+	"list":   "SELECT username FROM srp_verifiers",
+}
+
+// Returned by PutRevision when the stored verifier isn't the revision
+// the caller read. Also reported in the EventVerifierUpgraded event of
+// a Server whose upgrade was skipped because the verifier changed
+// during the login.
+type ErrorConflict string
+
+func (e ErrorConflict) Error() string {
+	return fmt.Sprintf("Verifier for user %q was changed by someone else.", string(e))
+}
+
+// Returns a replacer rewriting PostgreSQL statements for dialect.
+func sqlDialect(dialect string) (*strings.Replacer, error) {
+	switch dialect {
+	case DialectPostgres:
+		return strings.NewReplacer(), nil
+	case DialectSQLite:
+		//SQLite numbers its parameters ?1, ?2...
+		return strings.NewReplacer("BYTEA", "BLOB", "TIMESTAMPTZ", "TIMESTAMP", "$", "?"), nil
+	}
+
+	return nil, fmt.Errorf("Unknown SQL dialect %q.", dialect)
+}
+
+// Returns a store using db, which speaks dialect, after creating or
+// updating the tables it needs. The store doesn't close db.
+func OpenSQLStore(ctx context.Context, db *sql.DB, dialect string) (*SQLStore, error) {
+	replacer, err := sqlDialect(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = migrateSQL(ctx, db, replacer); err != nil {
+		return nil, err
+	}
+
+	s := &SQLStore{db: db}
+	for name, stmt := range map[string]**sql.Stmt{
+		"get":    &s.get,
+		"put":    &s.put,
+		"insert": &s.insert,
+		"update": &s.update,
+		"delete": &s.delete,
+		"list":   &s.list,
+	} {
+		if *stmt, err = db.PrepareContext(ctx, replacer.Replace(sqlStatements[name])); err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		s.statements = append(s.statements, *stmt)
+	}
+
+	return s, nil
+}
+
+// Applies the migrations the database hasn't had, in one transaction.
+func migrateSQL(ctx context.Context, db *sql.DB, replacer *strings.Replacer) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS srp_schema_version (version INTEGER NOT NULL)"); err != nil {
+		return err
+	}
+
+	version := 0
+	err = tx.QueryRowContext(ctx, "SELECT version FROM srp_schema_version").Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = tx.ExecContext(ctx, "INSERT INTO srp_schema_version (version) VALUES (0)")
+	}
+
+	if err != nil {
+		return err
+	} else if version > len(sqlMigrations) {
+		return fmt.Errorf("SRP schema version %d is newer than this library supports (%d).", version, len(sqlMigrations))
+	} else if version == len(sqlMigrations) {
+		return nil
+	}
+
+	for _, migration := range sqlMigrations[version:] {
+		if _, err = tx.ExecContext(ctx, replacer.Replace(migration)); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, replacer.Replace("UPDATE srp_schema_version SET version = $1"), len(sqlMigrations)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) Get(ctx context.Context, i string) (Verifier, error) {
+	v, _, err := s.GetRevision(ctx, i)
+	return v, err
+}
+
+// Returns the verifier for username i along with its revision, to be
+// handed to PutRevision.
+func (s *SQLStore) GetRevision(ctx context.Context, i string) (Verifier, int64, error) {
+	var v Verifier
+	var salt, verifier []byte
+	var updated time.Time
+	var revision int64
+
+	err := s.get.QueryRowContext(ctx, i).Scan(&v.I, &salt, &verifier, &v.Group, &v.Profile, &v.Version, &v.Created, &updated, &v.Pepper, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Verifier{}, 0, ErrorUnknownUser(i)
+	} else if err != nil {
+		return Verifier{}, 0, err
+	}
+
+	v.Salt.SetBytes(salt)
+	v.Verifier.SetBytes(verifier)
+	return v, revision, nil
+}
+
+func (s *SQLStore) Put(ctx context.Context, v Verifier) error {
+	if v.I == "" {
+		return new(EmptyUsernameError)
+	}
+
+	_, err := s.put.ExecContext(ctx, sqlArgs(&v)...)
+	return err
+}
+
+// Stores v if the stored verifier for v.I is still at revision, as
+// returned by GetRevision, or if revision is 0 and no verifier is
+// stored for v.I. Returns the new revision, or ErrorConflict.
+func (s *SQLStore) PutRevision(ctx context.Context, v Verifier, revision int64) (int64, error) {
+	if v.I == "" {
+		return 0, new(EmptyUsernameError)
+	}
+
+	var result sql.Result
+	var err error
+	if revision == 0 {
+		result, err = s.insert.ExecContext(ctx, sqlArgs(&v)...)
+	} else {
+		result, err = s.update.ExecContext(ctx, append(sqlArgs(&v), revision)...)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrorConflict(v.I)
+	}
+
+	return revision + 1, nil
+}
+
+func (s *SQLStore) Delete(ctx context.Context, i string) error {
+	result, err := s.delete.ExecContext(ctx, i)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrorUnknownUser(i)
+	}
+
+	return nil
+}
+
+func (s *SQLStore) List(ctx context.Context) ([]string, error) {
+	rows, err := s.list.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var i string
+		if err = rows.Scan(&i); err != nil {
+			return nil, err
+		}
+
+		names = append(names, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	//sorted here, as the database's collation may not order by bytes
+	sort.Strings(names)
+	return names, nil
+}
+
+// Releases the prepared statements. The database is left open.
+func (s *SQLStore) Close() error {
+	var err error
+	for _, stmt := range s.statements {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	s.statements = nil
+	return err
+}
+
+// Statement arguments for v, in the order of sqlColumns.
+func sqlArgs(v *Verifier) []interface{} {
+	return []interface{}{v.I, v.Salt.Bytes(), v.Verifier.Bytes(), v.Group, v.Profile, v.Version,
+		v.Created.UTC(), time.Now().UTC(), v.Pepper}
+}
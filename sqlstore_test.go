@@ -0,0 +1,98 @@
+package libgosrp
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	config, _ := GetProfile(ProfileRFC5054)
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "verifiers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store, err := OpenSQLStore(ctx, db, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []string{"carol", "alice", "bob"} {
+		var v Verifier
+		if _, err = v.New(i, "password123", 16, config); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = store.Put(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = store.Delete(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.Delete(ctx, "bob").(ErrorUnknownUser); !ok {
+		t.Error("Error: deleted a user twice.")
+	}
+	store.Close()
+
+	//migrations already applied are skipped
+	reopened, err := OpenSQLStore(ctx, db, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	names, _ := reopened.List(ctx)
+	if !reflect.DeepEqual(names, []string{"alice", "carol"}) {
+		t.Errorf("Error: wrong users after reopening: %v", names)
+	}
+
+	v, revision, err := reopened.GetRevision(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testhandshake(t, v, "password123", config, config)
+
+	if v.Profile != ProfileRFC5054 || v.Version != VerifierVersion || time.Since(v.Created) > time.Minute {
+		t.Errorf("Error: verifier details not stored: %+v", v)
+	}
+
+	//the first writer to use a revision wins
+	if revision, err = reopened.PutRevision(ctx, v, revision); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = reopened.PutRevision(ctx, v, revision-1); err == nil {
+		t.Error("Error: stale revision was written.")
+	} else if _, ok := err.(ErrorConflict); !ok {
+		t.Error("Error: stale revision gave the wrong error:", err)
+	}
+
+	if _, err = reopened.PutRevision(ctx, v, 0); err == nil {
+		t.Error("Error: inserted over an existing user.")
+	}
+
+	v.I, v.Pepper = "dave", "p1"
+	if revision, err = reopened.PutRevision(ctx, v, 0); err != nil || revision != 1 {
+		t.Error("Error: inserting a new user failed:", err)
+	}
+
+	if v, _ = reopened.Get(ctx, "dave"); v.Pepper != "p1" {
+		t.Error("Error: pepper id not stored.")
+	}
+
+	if _, err = reopened.Get(ctx, "bob"); err == nil {
+		t.Error("Error: deleted user still stored.")
+	}
+}
@@ -1,19 +1,29 @@
 package libgosrp
 
 import (
+	"context"
 	"math/big"
 )
 
 type SRPClientSession struct {
-	config         *SRPConfig
-	i              string //username
-	hashed_pass    big.Int //hashed password
-	s, a           big.Int //salt, private ephemeral value
-	biga, bigb     big.Int //public ephemeral value
-	session_key    big.Int
+	config      *SRPConfig
+	i           string  //username
+	hashed_pass big.Int //hashed password
+	s, a        big.Int //salt, private ephemeral value
+	biga, bigb  big.Int //public ephemeral value
+	session_key []byte
+	m1, m2      []byte //client and server proofs
+	closed      bool
+	transcript  *Transcript
 }
 
 func (s *SRPClientSession) New(i string, config *SRPConfig) (*SRPClientSession, error) {
+	return s.NewContext(context.Background(), i, config)
+}
+
+// As New, but gives up with ctx.Err() if ctx is done before A is
+// computed.
+func (s *SRPClientSession) NewContext(ctx context.Context, i string, config *SRPConfig) (*SRPClientSession, error) {
 	if err := config.check_init(); err != nil {
 		return new(SRPClientSession), err
 	}
@@ -25,22 +35,229 @@ func (s *SRPClientSession) New(i string, config *SRPConfig) (*SRPClientSession,
 	var err error
 	s.config = config
 	s.i = i
-	s.a, err = config.abgen(64)
-	s.biga = s.calculate_biga()
+	s.a, err = config.abgen(ephemeralLen)
+	if err == nil {
+		err = ctx.Err()
+	}
 
 	if err != nil {
+		wipe(&s.a)
 		return new(SRPClientSession), err
 	}
 
+	s.biga = s.calculate_biga()
+
+	s.record_setup()
 	return s, nil
 }
 
-func (s *SRPClientSession) calculate_biga() big.Int {
-	var biga big.Int
+// Records the session's inputs and intermediate values in t, for
+// debugging interoperability. Call before New to include the values
+// New computes. The transcript holds secrets, including the password,
+// which Close doesn't wipe; see Transcript.Redact().
+func (s *SRPClientSession) SetTranscript(t *Transcript) {
+	s.transcript = t
+	if t != nil {
+		t.Role = "client"
+	}
+
+	if s.config != nil {
+		s.record_setup()
+	}
+}
+
+func (s *SRPClientSession) record_setup() {
+	t := s.transcript
+	if t == nil {
+		return
+	}
+
+	t.config = s.config
+	t.Profile = s.config.Profile()
+	t.record("N", &s.config.gp.N, false)
+	t.record("g", &s.config.gp.G, false)
+	t.record_text("I", s.i, false)
+	t.record("a", &s.a, true)
+	t.record("A", &s.biga, false)
+}
 
+// Returns the username and public ephemeral value A to be sent to
+// the server.
+func (s *SRPClientSession) Hello() (ClientHello, error) {
+	if err := s.check_open(); err != nil {
+		return ClientHello{}, err
+	}
+
+	return ClientHello{s.i, s.config.element(&s.biga)}, nil
+}
+
+// Reads the salt and B sent by the server and derives the session key
+// from them and the password p.
+func (s *SRPClientSession) ReadChallenge(challenge ServerChallenge, p string) error {
+	return s.ReadChallengeContext(context.Background(), challenge, p)
+}
+
+// As ReadChallenge, but gives up with ctx.Err() once ctx is done,
+// closing the session. The password hash is only stopped partway if
+// the config has a context hash; see SRPConfig.SetContextHash.
+func (s *SRPClientSession) ReadChallengeContext(ctx context.Context, challenge ServerChallenge, p string) error {
+	if err := s.check_open(); err != nil {
+		return err
+	}
+
+	salt := s.config.decode(challenge.Salt)
+	bigb := s.config.decode(challenge.B)
+	if s.config.is_zero(&bigb) {
+		s.Close()
+		return ErrorIllegalParameter("B")
+	}
+
+	u := s.config.calculate_u(&s.biga, &bigb)
+	if u.Sign() == 0 {
+		s.Close()
+		return ErrorIllegalParameter("u")
+	}
+
+	s.s = salt
+	s.bigb = bigb
+	var err error
+	if s.hashed_pass, err = s.config.calculate_x_context(ctx, s.i, p, &s.s); err == nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		s.Close()
+		return err
+	}
+
+	//S = (B - kg^x) ^ (a + ux)
 	gp := s.config.gp
-	biga.Exp(&gp.G, &s.a, &gp.N)
-	return biga
+	k := s.config.calculate_k()
+
+	var exp big.Int
+	base := s.config.exp_g(&s.hashed_pass, s.config.x_bits())
+	if err = ctx.Err(); err != nil {
+		wipe(&base)
+		s.Close()
+		return err
+	}
+
+	base.Mul(&base, &k)
+	base.Sub(&s.bigb, &base)
+	base.Mod(&base, &gp.N)
+
+	exp.Mul(&u, &s.hashed_pass)
+	exp.Add(&exp, &s.a)
+
+	//a + ux is no wider than u and x together, plus a carry
+	bound := u.BitLen() + s.config.x_bits() + 1
+	if bound < 8*ephemeralLen+1 {
+		bound = 8*ephemeralLen + 1
+	}
+	premaster := s.config.exp_secret(&base, &exp, bound)
+
+	if t := s.transcript; t != nil {
+		t.record_text("P", p, true)
+		t.record("s", &s.s, false)
+		t.record("B", &s.bigb, false)
+		t.record("x", &s.hashed_pass, true)
+		t.record("k", &k, false)
+		t.record("u", &u, false)
+		t.record("S", &premaster, true)
+	}
+
+	//only S is needed from here on
+	for _, n := range []*big.Int{&base, &exp, &s.hashed_pass} {
+		wipe(n)
+	}
+	defer wipe(&premaster)
+
+	s.session_key = s.config.calculate_session_key(&premaster)
+	s.m1 = s.config.calculate_m1(s.i, &s.s, &s.biga, &s.bigb, &premaster, s.session_key)
+	s.m2 = s.config.calculate_m2(&s.biga, &premaster, s.m1, s.session_key)
+
+	if t := s.transcript; t != nil {
+		t.record_bytes("K", s.session_key, true)
+		t.record_bytes("M1", s.m1, false)
+		t.record_bytes("M2", s.m2, false)
+	}
+
+	return nil
+}
+
+// Returns the client's proof M1.
+func (s *SRPClientSession) Proof() (ClientProof, error) {
+	if err := s.check_open(); err != nil {
+		return ClientProof{}, err
+	}
+
+	if s.session_key == nil {
+		return ClientProof{}, ErrorSessionState("ReadChallenge must be called before Proof")
+	}
+
+	return ClientProof{append([]byte(nil), s.m1...)}, nil
+}
+
+// Checks the server's proof M2. Returns ErrorBadProof if the server
+// did not derive the same session key.
+func (s *SRPClientSession) ReadProof(p ServerProof) error {
+	if err := s.check_open(); err != nil {
+		return err
+	}
+
+	if s.session_key == nil {
+		return ErrorSessionState("ReadChallenge must be called before ReadProof")
+	}
+
+	if !proofs_equal(p.M2, s.m2) {
+		s.Close()
+		return ErrorBadProof("server")
+	}
+
+	return nil
+}
+
+// Returns a copy of the shared session key K, or nil if it has not
+// been derived.
+func (s *SRPClientSession) SessionKey() []byte {
+	if s.session_key == nil {
+		return nil
+	}
+
+	return append([]byte(nil), s.session_key...)
+}
+
+// Overwrites the session's secrets: a, the hashed password, the
+// session key and both proofs. The session can't be used afterwards.
+// Sessions close themselves when the handshake fails.
+func (s *SRPClientSession) Close() error {
+	wipe(&s.a)
+	wipe(&s.hashed_pass)
+	wipe_bytes(s.session_key)
+	wipe_bytes(s.m1)
+	wipe_bytes(s.m2)
+
+	s.session_key, s.m1, s.m2 = nil, nil, nil
+	s.closed = true
+	return nil
+}
+
+func (s *SRPClientSession) check_open() error {
+	if s.closed {
+		return ErrorSessionState("session is closed")
+	}
+
+	//check_init returns a pointer, which mustn't become a non-nil
+	//error
+	if err := s.config.check_init(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SRPClientSession) calculate_biga() big.Int {
+	return s.config.exp_g(&s.a, 8*ephemeralLen)
 }
 
 type EmptyUsernameError int
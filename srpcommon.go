@@ -2,14 +2,17 @@ package libgosrp
 
 import (
 	"code.google.com/p/go.crypto/pbkdf2"
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha512"
 	"fmt"
+	"hash"
 	"io"
 	"math/big"
 )
 
-//example hash function
+// example hash function
 func H(to_hash, salt []byte) big.Int {
 	var x big.Int
 	dk := pbkdf2.Key(to_hash, salt, 10000, 128, sha512.New)
@@ -19,6 +22,62 @@ func H(to_hash, salt []byte) big.Int {
 	return x
 }
 
+// As H, but gives up with ctx.Err() once ctx is done. For use with
+// SRPConfig.SetContextHash, in place of H.
+func HContext(ctx context.Context, to_hash, salt []byte) (big.Int, error) {
+	return PBKDF2(10000, 128, sha512.New)(ctx, to_hash, salt)
+}
+
+// Returns a password hash giving x = PBKDF2(p, s) with iter iterations
+// of HMAC over newhash, keyLen bytes long. The hash checks ctx as it
+// goes, and gives up with ctx.Err() once ctx is done. For use with
+// SRPConfig.SetContextHash.
+func PBKDF2(iter, keyLen int, newhash func() hash.Hash) func(context.Context, []byte, []byte) (big.Int, error) {
+	return func(ctx context.Context, to_hash, salt []byte) (big.Int, error) {
+		var x big.Int
+
+		prf := hmac.New(newhash, to_hash)
+		size := prf.Size()
+		dk := make([]byte, 0, (keyLen+size-1)/size*size)
+		u := make([]byte, 0, size)
+		t := make([]byte, size)
+		defer wipe_bytes(dk[:cap(dk)])
+		defer wipe_bytes(u[:cap(u)])
+		defer wipe_bytes(t)
+
+		for block := uint32(1); len(dk) < keyLen; block++ {
+			//T_i = U_1 xor U_2 xor ... xor U_iter, where U_1 =
+			//PRF(p, s | INT(i)) and U_j = PRF(p, U_j-1)
+			prf.Reset()
+			prf.Write(salt)
+			prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+			u = prf.Sum(u[:0])
+			copy(t, u)
+
+			for n := 1; n < iter; n++ {
+				//every 1000 iterations is about a millisecond
+				if n%1000 == 0 {
+					if err := ctx.Err(); err != nil {
+						return x, err
+					}
+				}
+
+				prf.Reset()
+				prf.Write(u)
+				u = prf.Sum(u[:0])
+				for j := range t {
+					t[j] ^= u[j]
+				}
+			}
+
+			dk = append(dk, t...)
+		}
+
+		x.SetBytes(dk[:keyLen])
+		return x, nil
+	}
+}
+
 // Creates an entirely random salt of length slen.
 // For use with Create, if you only need to specify a hash function.
 func RandomBytes(slen uint) (big.Int, error) {
@@ -46,6 +105,75 @@ func (e ErrorShortBytes) Error() string {
 	return fmt.Sprintf("Generated salt is was shorter than requested. Expected length %d, got length %d.", e.slen, e.n)
 }
 
+// Byte order used when converting between integers and byte strings.
+type ByteOrder int
+
+const (
+	BigEndian ByteOrder = iota
+	LittleEndian
+)
+
+// Method of deriving the session key K from the premaster secret S.
+type SessionKeyDerivation int
+
+const (
+	// K = H(S)
+	SessionKeyHash SessionKeyDerivation = iota
+	// K = SHA_Interleave(S), as in RFC 2945. K is twice the length
+	// of the hash output.
+	SessionKeyInterleave
+)
+
+// Formulas for the client and server proofs M1 and M2.
+type ProofFormula int
+
+const (
+	// M1 = H(H(N) xor H(g) | H(I) | s | A | B | K), M2 = H(A | M1 | K),
+	// as in RFC 2945 and the SRP design paper.
+	ProofRFC2945 ProofFormula = iota
+	// M1 = H(A | B | S), M2 = H(A | M1 | S), as in Nimbus SRP and
+	// Thinbus.
+	ProofABS
+)
+
+// Credentials function that hands the password alone to the
+// password hash. The caller is free to fold the username into p.
+func PasswordOnly(i, p string) []byte {
+	return []byte(p)
+}
+
+// Credentials function giving I | ":" | P, as used by RFC 2945 and
+// RFC 5054.
+func UsernamePassword(i, p string) []byte {
+	return []byte(i + ":" + p)
+}
+
+type ErrorIllegalParameter string
+
+func (e ErrorIllegalParameter) Error() string {
+	return fmt.Sprintf("Illegal SRP parameter: %s is 0 mod N.", string(e))
+}
+
+type ErrorBadProof string
+
+func (e ErrorBadProof) Error() string {
+	return fmt.Sprintf("Authentication failed: %s proof did not match.", string(e))
+}
+
+type ErrorSessionState string
+
+func (e ErrorSessionState) Error() string {
+	return fmt.Sprintf("SRP session not ready: %s.", string(e))
+}
+
+// The username in a ClientHello, when it isn't the one the session's
+// verifier belongs to.
+type ErrorUsernameMismatch string
+
+func (e ErrorUsernameMismatch) Error() string {
+	return fmt.Sprintf("SRP hello from %q does not match the session's verifier.", string(e))
+}
+
 func Pad(length int, src []byte) []byte {
 	if len(src) > length {
 		//error
@@ -73,44 +201,17 @@ func (gp *SRPGroupParameters) isEmpty() bool {
 type ErrNoPrimeAvailable int
 
 func (e ErrNoPrimeAvailable) Error() string {
-	return fmt.Sprintf("No standard %v-bit prime defined by this package!", e)
+	return fmt.Sprintf("No standard %d-bit prime defined by this package! See GenerateGroupParameters.", int(e))
 }
 
 // Takes the size in bits of the desired prime number, and returns
-// an SRPGroupParameters object with the prime and the generator.
+// an SRPGroupParameters object with the prime and the generator from
+// RFC 5054. See LookupGroup for other groups.
 func GetGroupParameters(Nsize int) (SRPGroupParameters, error) {
-	var prime, generator big.Int
-
-	switch Nsize {
-	case 1024:
-		prime.SetString("EEAF0AB9ADB38DD69C33F80AFA8FC5E86072618775FF3C0B9EA2314C9C256576D674DF7496EA81D3383B4813D692C6E0E0D5D8E250B98BE48E495C1D6089DAD15DC7D7B46154D6B6CE8EF4AD69B15D4982559B297BCF1885C529F566660E57EC68EDBC3C05726CC02FD4CBF4976EAA9AFD5138FE8376435B9FC61D2FC0EB06E3", 16)
-		generator.SetString("2", 10)
-		return SRPGroupParameters{prime, generator}, nil
-	case 1536:
-		prime.SetString("9DEF3CAFB939277AB1F12A8617A47BBBDBA51DF499AC4C80BEEEA9614B19CC4D5F4F5F556E27CBDE51C6A94BE4607A291558903BA0D0F84380B655BB9A22E8DCDF028A7CEC67F0D08134B1C8B97989149B609E0BE3BAB63D47548381DBC5B1FC764E3F4B53DD9DA1158BFD3E2B9C8CF56EDF019539349627DB2FD53D24B7C48665772E437D6C7F8CE442734AF7CCB7AE837C264AE3A9BEB87F8A2FE9B8B5292E5A021FFF5E91479E8CE7A28C2442C6F315180F93499A234DCF76E3FED135F9BB", 16)
-		generator.SetString("2", 10)
-		return SRPGroupParameters{prime, generator}, nil
-	case 2048:
-		prime.SetString("AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050A37329CBB4A099ED8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B855F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773BCA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB694B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73", 16)
-		generator.SetString("2", 10)
-		return SRPGroupParameters{prime, generator}, nil
-	case 3072:
-		prime.SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E208E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF", 16)
-		generator.SetString("5", 10)
-		return SRPGroupParameters{prime, generator}, nil
-	case 4096:
-		prime.SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E208E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D788719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA993B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF", 16)
-		generator.SetString("5", 10)
-		return SRPGroupParameters{prime, generator}, nil
-	case 6144:
-		prime.SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E208E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D788719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA993B4EA988D8FDDC186FFB7DC90A6C08F4DF435C93402849236C3FAB4D27C7026C1D4DCB2602646DEC9751E763DBA37BDF8FF9406AD9E530EE5DB382F413001AEB06A53ED9027D831179727B0865A8918DA3EDBEBCF9B14ED44CE6CBACED4BB1BDB7F1447E6CC254B332051512BD7AF426FB8F401378CD2BF5983CA01C64B92ECF032EA15D1721D03F482D7CE6E74FEF6D55E702F46980C82B5A84031900B1C9E59E7C97FBEC7E8F323A97A7E36CC88BE0F1D45B7FF585AC54BD407B22B4154AACC8F6D7EBF48E1D814CC5ED20F8037E0A79715EEF29BE32806A1D58BB7C5DA76F550AA3D8A1FBFF0EB19CCB1A313D55CDA56C9EC2EF29632387FE8D76E3C0468043E8F663F4860EE12BF2D5B0B7474D6E694F91E6DCC4024FFFFFFFFFFFFFFFF", 16)
-		generator.SetString("5", 10)
-		return SRPGroupParameters{prime, generator}, nil
-	case 8192:
-		prime.SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E208E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D788719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA993B4EA988D8FDDC186FFB7DC90A6C08F4DF435C93402849236C3FAB4D27C7026C1D4DCB2602646DEC9751E763DBA37BDF8FF9406AD9E530EE5DB382F413001AEB06A53ED9027D831179727B0865A8918DA3EDBEBCF9B14ED44CE6CBACED4BB1BDB7F1447E6CC254B332051512BD7AF426FB8F401378CD2BF5983CA01C64B92ECF032EA15D1721D03F482D7CE6E74FEF6D55E702F46980C82B5A84031900B1C9E59E7C97FBEC7E8F323A97A7E36CC88BE0F1D45B7FF585AC54BD407B22B4154AACC8F6D7EBF48E1D814CC5ED20F8037E0A79715EEF29BE32806A1D58BB7C5DA76F550AA3D8A1FBFF0EB19CCB1A313D55CDA56C9EC2EF29632387FE8D76E3C0468043E8F663F4860EE12BF2D5B0B7474D6E694F91E6DBE115974A3926F12FEE5E438777CB6A932DF8CD8BEC4D073B931BA3BC832B68D9DD300741FA7BF8AFC47ED2576F6936BA424663AAB639C5AE4F5683423B4742BF1C978238F16CBE39D652DE3FDB8BEFC848AD922222E04A4037C0713EB57A81A23F0C73473FC646CEA306B4BCBC8862F8385DDFA9D4B7FA2C087E879683303ED5BDD3A062B3CF5B3A278A66D2A13F83F44F82DDF310EE074AB6A364597E899A0255DC164F31CC50846851DF9AB48195DED7EA1B1D510BD7EE74D73FAF36BC31ECFA268359046F4EB879F924009438B481C6CD7889A002ED5EE382BC9190DA6FC026E479558E4475677E9AA9E3050E2765694DFC81F56E880B96E7160C980DD98EDD3DFFFFFFFFFFFFFFFFF", 16)
-		generator.SetString("19", 10)
-		return SRPGroupParameters{prime, generator}, nil
-	default:
+	gp, err := LookupGroup(fmt.Sprintf("rfc5054-%d", Nsize))
+	if err != nil {
 		return SRPGroupParameters{}, ErrNoPrimeAvailable(Nsize)
 	}
+
+	return gp, nil
 }
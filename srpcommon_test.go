@@ -1,7 +1,11 @@
 package libgosrp
 
 import (
+	"context"
+	"crypto/sha1"
+	"errors"
 	"testing"
+	"time"
 )
 
 // Runs RandomBytes. Errors if err is non-nil
@@ -15,3 +19,34 @@ func TestRandomBytes(t *testing.T) {
 
 	t.Logf("Salt: %X", n.Bytes())
 }
+
+func TestHContext(t *testing.T) {
+	x := H([]byte("password123"), []byte("salt"))
+	y, err := HContext(context.Background(), []byte("password123"), []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x.Cmp(&y) != 0 {
+		t.Errorf("Error: HContext and H differ.\nH: %X\nHContext: %X", x.Bytes(), y.Bytes())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = HContext(ctx, []byte("password123"), []byte("salt")); !errors.Is(err, context.Canceled) {
+		t.Error("Error: HContext ignored a cancelled context: ", err)
+	}
+
+	//far more iterations than the deadline allows
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err = PBKDF2(100000000, 20, sha1.New)(ctx, []byte("password123"), []byte("salt")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Error: PBKDF2 ran past its deadline: ", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("Error: PBKDF2 took %v to notice its deadline.", time.Since(start))
+	}
+}
@@ -5,19 +5,62 @@
 package libgosrp
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"math/big"
+	"math/bits"
+	"sync"
 )
 
 type SRPConfig struct {
-	gp SRPGroupParameters
-	h func([]byte, []byte) big.Int
+	gp   SRPGroupParameters
+	h    func([]byte, []byte) big.Int
 	sgen func(uint) (big.Int, error)
+	//password hash that can be cancelled, used in place of h
+	//when set
+	hctx func(context.Context, []byte, []byte) (big.Int, error)
 	//generator for private ephemeral values
 	//only defined here for testing purposes
 	//(replaced with function that gives predictable value)
-	abgen func(uint) (big.Int, error)
+	abgen      func(uint) (big.Int, error)
 	pad_values bool
+	//hash used for k, u, K, M1 and M2. If nil, h is
+	//used with an empty salt.
+	hash  func() hash.Hash
+	order ByteOrder
+	//fixed multiplier, for protocols that don't derive k
+	//from N and g
+	k           *big.Int
+	reduce_bigb bool
+	session_key SessionKeyDerivation
+	proofs      ProofFormula
+	hex_hashing bool
+	//formats the username and password before they're
+	//handed to h
+	credentials func(string, string) []byte
+	//name of the profile this config was made from, if any
+	profile string
+	//build a table of powers of g on first use
+	precompute bool
+	//opens peppered verifiers, and seals new ones
+	pepper  *Pepper
+	metrics Metrics
+	cache   *configCache
+}
+
+// Values derived from the group and the hashing settings, computed on
+// first use and shared by every session using the config.
+type configCache struct {
+	mu    sync.Mutex
+	gp    SRPGroupParameters //group the values belong to
+	k     *big.Int
+	mont  *montgomery
+	fixed *fixedBase
+	xbits int    //public bound on the size of x
+	kdfid string //fingerprint of the password hash
 }
 
 func (s *SRPConfig) New(srpgp SRPGroupParameters, hash func([]byte, []byte) big.Int, salt_gen func(uint) (big.Int, error)) *SRPConfig {
@@ -26,12 +69,216 @@ func (s *SRPConfig) New(srpgp SRPGroupParameters, hash func([]byte, []byte) big.
 	s.sgen = salt_gen
 	s.abgen = RandomBytes
 	s.pad_values = true
+	s.order = BigEndian
+	s.credentials = PasswordOnly
+	s.cache = new(configCache)
 
 	return s
 }
 
+// Replaces the group, for using a profile with a group other than its
+// own.
+func (s *SRPConfig) SetGroup(gp SRPGroupParameters) {
+	s.gp = gp.copy()
+}
+
 func (s *SRPConfig) SetPad(value bool) {
 	s.pad_values = value
+	s.cache = new(configCache)
+}
+
+// Sets the hash used for the protocol values k, u, K, M1 and M2.
+// Without one, the password hash handed to New() is used for these
+// as well.
+func (s *SRPConfig) SetHash(hash func() hash.Hash) {
+	s.hash = hash
+	s.cache = new(configCache)
+}
+
+// Replaces the password hash handed to New() with one that takes a
+// context, such as HContext or PBKDF2, so that the context variants
+// of the session and verifier constructors can stop hashing when
+// their context is done. hash must give the same x as the hash it
+// replaces, and should only fail with ctx.Err().
+func (s *SRPConfig) SetContextHash(hash func(context.Context, []byte, []byte) (big.Int, error)) {
+	s.hctx = hash
+	s.cache = new(configCache)
+	s.h = func(to_hash, salt []byte) big.Int {
+		x, _ := hash(context.Background(), to_hash, salt)
+		return x
+	}
+}
+
+// Sets the byte order used to convert between integers and the byte
+// strings that are hashed and sent over the wire.
+func (s *SRPConfig) SetByteOrder(order ByteOrder) {
+	s.order = order
+	s.cache = new(configCache)
+}
+
+// Uses a constant k instead of k = H(N | PAD(g)), as in SRP-6.
+func (s *SRPConfig) SetMultiplier(k big.Int) {
+	s.k = new(big.Int).Set(&k)
+}
+
+// When set, B is reduced mod N, as required by RFC 5054. Off by
+// default for compatibility with verifiers and tests written against
+// earlier versions of this package.
+func (s *SRPConfig) SetReduceB(value bool) {
+	s.reduce_bigb = value
+}
+
+// Sets how the session key K is derived from the premaster secret S.
+func (s *SRPConfig) SetSessionKeyDerivation(kdf SessionKeyDerivation) {
+	s.session_key = kdf
+}
+
+// Sets the formulas used for the proofs M1 and M2.
+func (s *SRPConfig) SetProofs(proofs ProofFormula) {
+	s.proofs = proofs
+}
+
+// When set, integers are hashed as their lowercase hex strings rather
+// than as bytes, as done by Thinbus.
+func (s *SRPConfig) SetHexHashing(value bool) {
+	s.hex_hashing = value
+	s.cache = new(configCache)
+}
+
+// When set, a table of powers of g is built the first time the config
+// is used, making A, B and verifiers several times faster to compute.
+// The table takes 32 times the square of N's size in bytes: 4.5MB for
+// a 3072-bit group, 8MB for 4096 bits.
+func (s *SRPConfig) SetPrecompute(value bool) {
+	s.precompute = value
+}
+
+// Sets the pepper that opens peppered verifiers in SRPSession.New
+// and seals those made by Verifier.New. Only servers need a pepper.
+func (s *SRPConfig) SetPepper(pepper *Pepper) {
+	s.pepper = pepper
+}
+
+// Sets where sessions and verifiers made with the config, and Servers
+// using it, report their measurements.
+func (s *SRPConfig) SetMetrics(metrics Metrics) {
+	s.metrics = metrics
+}
+
+// Sets the function that combines the username and password into the
+// input of the password hash.
+func (s *SRPConfig) SetCredentials(credentials func(string, string) []byte) {
+	s.credentials = credentials
+	s.cache = new(configCache)
+}
+
+// Returns the name of the profile the config was made from, or "" if
+// it was set up by hand.
+func (s *SRPConfig) Profile() string {
+	return s.profile
+}
+
+// Returns the registered name of the config's group, or its
+// fingerprint if it is not registered.
+func (s *SRPConfig) GroupID() string {
+	if name := s.gp.Name(); name != "" {
+		return name
+	}
+
+	return s.gp.Fingerprint()
+}
+
+// Locks the cache, emptying it if the group has changed since it was
+// filled.
+func (s *SRPConfig) lock_cache() *configCache {
+	if s.cache == nil {
+		s.cache = new(configCache)
+	}
+
+	cc := s.cache
+	cc.mu.Lock()
+	if !cc.gp.Equal(s.gp) {
+		cc.gp = s.gp.copy()
+		cc.k, cc.mont, cc.fixed = nil, nil, nil
+	}
+
+	return cc
+}
+
+func (s *SRPConfig) montgomery() *montgomery {
+	cc := s.lock_cache()
+	defer cc.mu.Unlock()
+
+	if cc.mont == nil {
+		cc.mont = newMontgomery(&s.gp.N)
+	}
+
+	return cc.mont
+}
+
+func (s *SRPConfig) fixed_base() *fixedBase {
+	cc := s.lock_cache()
+	defer cc.mu.Unlock()
+
+	if cc.fixed == nil {
+		if cc.mont == nil {
+			cc.mont = newMontgomery(&s.gp.N)
+		}
+
+		cc.fixed = newFixedBase(cc.mont, &s.gp.G)
+	}
+
+	return cc.fixed
+}
+
+// Returns a public bound on the size of x in bits, for exponentiating
+// by it: the size of the password hash's output, found by hashing an
+// empty password the first time, rounded up to whole words so that
+// leading zeros in that one hash don't matter.
+func (s *SRPConfig) x_bits() int {
+	cc := s.lock_cache()
+	defer cc.mu.Unlock()
+
+	if cc.xbits == 0 {
+		x := s.h([]byte{}, []byte{})
+		cc.xbits = (x.BitLen() + bits.UintSize - 1) / bits.UintSize * bits.UintSize
+		if cc.xbits == 0 {
+			cc.xbits = bits.UintSize
+		}
+	}
+
+	return cc.xbits
+}
+
+// Returns the profile recorded in verifiers made with the config: the
+// name of its profile, or for a config set up by hand, customProfile
+// followed by a fingerprint of x for a fixed username, password and
+// salt. Working out the fingerprint costs one password hash, the
+// first time.
+func (s *SRPConfig) verifier_profile() string {
+	if s.profile != "" {
+		return s.profile
+	}
+
+	cc := s.lock_cache()
+	id := cc.kdfid
+	cc.mu.Unlock()
+
+	if id == "" {
+		//without metrics, so the probe isn't counted as a login
+		probe := *s
+		probe.metrics = nil
+		x := probe.calculate_x("srp-probe", "srp-probe", big.NewInt(1))
+		sum := sha256.Sum256(x.Bytes())
+		wipe(&x)
+		id = customProfile + hex.EncodeToString(sum[:8])
+
+		cc = s.lock_cache()
+		cc.kdfid = id
+		cc.mu.Unlock()
+	}
+
+	return id
 }
 
 func (s *SRPConfig) check_init() *ErrorUninitializedSRPConfig {
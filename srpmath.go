@@ -0,0 +1,248 @@
+package libgosrp
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Serializes n in the configured byte order, left padded (or, for
+// little-endian, right padded) with zeros to width bytes. A width of
+// 0 gives the shortest representation.
+func (c *SRPConfig) encode(n *big.Int, width int) []byte {
+	b := n.Bytes()
+	if len(b) < width {
+		b = Pad(width, b)
+	}
+
+	if c.order == LittleEndian {
+		reverse(b)
+	}
+
+	return b
+}
+
+// Inverse of encode.
+func (c *SRPConfig) decode(b []byte) big.Int {
+	var n big.Int
+
+	if c.order == LittleEndian {
+		le := make([]byte, len(b))
+		copy(le, b)
+		reverse(le)
+		b = le
+	}
+
+	n.SetBytes(b)
+	return n
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// Length of N in bytes.
+func (c *SRPConfig) nlen() int {
+	return len(c.gp.N.Bytes())
+}
+
+// Serializes a salt or a member of the group. Little-endian values
+// are kept at the full width of N, the way implementations using that
+// convention store them in fixed size arrays.
+func (c *SRPConfig) element(n *big.Int) []byte {
+	if c.order == LittleEndian {
+		return c.encode(n, c.nlen())
+	}
+
+	return c.encode(n, 0)
+}
+
+// Serializes n as hash input, padded to the length of N if pad is set
+// (PAD() in RFC 5054). With hex hashing, this is the lowercase hex
+// string of n, without leading zeros unless padded.
+func (c *SRPConfig) hashable(n *big.Int, pad bool) []byte {
+	var b []byte
+	if pad {
+		b = c.encode(n, c.nlen())
+	} else {
+		b = c.element(n)
+	}
+
+	if c.hex_hashing {
+		h := hex.EncodeToString(b)
+		if !pad {
+			h = strings.TrimLeft(h, "0")
+		}
+
+		return []byte(h)
+	}
+
+	return b
+}
+
+// Hashes the concatenation of parts with the protocol hash.
+func (c *SRPConfig) digest(parts ...[]byte) []byte {
+	if c.hash == nil {
+		var input []byte
+		for _, p := range parts {
+			input = append(input, p...)
+		}
+
+		h := c.h(input, make([]byte, 0))
+		return h.Bytes()
+	}
+
+	h := c.hash()
+	for _, p := range parts {
+		h.Write(p)
+	}
+
+	return h.Sum(nil)
+}
+
+// x = H(s, p)
+func (c *SRPConfig) calculate_x(i, p string, salt *big.Int) big.Int {
+	x, _ := c.calculate_x_context(context.Background(), i, p, salt)
+	return x
+}
+
+// As calculate_x, but stops when ctx is done. Only a context hash can
+// be stopped partway; with any other, ctx is checked before hashing.
+func (c *SRPConfig) calculate_x_context(ctx context.Context, i, p string, salt *big.Int) (big.Int, error) {
+	if err := ctx.Err(); err != nil {
+		return big.Int{}, err
+	}
+	defer c.measure_kdf(time.Now())
+
+	credentials := c.credentials(i, p)
+	defer wipe_bytes(credentials)
+
+	if c.hctx != nil {
+		return c.hctx(ctx, credentials, c.element(salt))
+	}
+
+	return c.h(credentials, c.element(salt)), nil
+}
+
+// k = H(N | PAD(g)), unless a constant multiplier was configured
+func (c *SRPConfig) calculate_k() big.Int {
+	if c.k != nil {
+		return *new(big.Int).Set(c.k)
+	}
+
+	cc := c.lock_cache()
+	defer cc.mu.Unlock()
+
+	if cc.k == nil {
+		k := c.decode(c.digest(c.hashable(&c.gp.N, false), c.hashable(&c.gp.G, c.pad_values)))
+		cc.k = &k
+	}
+
+	return *new(big.Int).Set(cc.k)
+}
+
+// u = H(PAD(A) | PAD(B))
+func (c *SRPConfig) calculate_u(biga, bigb *big.Int) big.Int {
+	return c.decode(c.digest(c.hashable(biga, c.pad_values), c.hashable(bigb, c.pad_values)))
+}
+
+// K = H(S), or SHA_Interleave(S)
+func (c *SRPConfig) calculate_session_key(premaster *big.Int) []byte {
+	switch c.session_key {
+	case SessionKeyInterleave:
+		t := c.element(premaster)
+		defer wipe_bytes(t)
+		return c.sha_interleave(t)
+	default:
+		t := c.hashable(premaster, false)
+		defer wipe_bytes(t)
+		return c.digest(t)
+	}
+}
+
+// SHA_Interleave from RFC 2945: leading zero bytes are removed (along
+// with one more byte if that leaves an odd number), the even and odd
+// bytes are hashed separately and the two digests interleaved.
+func (c *SRPConfig) sha_interleave(t []byte) []byte {
+	for len(t) > 0 && t[0] == 0 {
+		t = t[1:]
+	}
+
+	if len(t)%2 == 1 {
+		t = t[1:]
+	}
+
+	e := make([]byte, len(t)/2)
+	f := make([]byte, len(t)/2)
+	for i := range e {
+		e[i] = t[2*i]
+		f[i] = t[2*i+1]
+	}
+
+	g := c.digest(e)
+	h := c.digest(f)
+	wipe_bytes(e)
+	wipe_bytes(f)
+
+	k := make([]byte, 0, len(g)+len(h))
+	for i := range g {
+		k = append(k, g[i], h[i])
+	}
+
+	return k
+}
+
+// M1 = H(H(N) xor H(g) | H(I) | s | A | B | K), or H(A | B | S)
+func (c *SRPConfig) calculate_m1(i string, salt, biga, bigb, premaster *big.Int, key []byte) []byte {
+	if c.proofs == ProofABS {
+		t := c.hashable(premaster, false)
+		defer wipe_bytes(t)
+		return c.digest(c.hashable(biga, false), c.hashable(bigb, false), t)
+	}
+
+	//g is hashed at its natural width, even when other values
+	//are fixed width
+	hn := c.digest(c.hashable(&c.gp.N, false))
+	hg := c.digest(c.encode(&c.gp.G, 0))
+
+	if len(hg) < len(hn) {
+		hg = Pad(len(hn), hg)
+	} else if len(hn) < len(hg) {
+		hn = Pad(len(hg), hn)
+	}
+
+	for j := range hn {
+		hn[j] ^= hg[j]
+	}
+
+	return c.digest(hn, c.digest([]byte(i)), c.hashable(salt, false), c.hashable(biga, false), c.hashable(bigb, false), key)
+}
+
+// M2 = H(A | M1 | K), or H(A | M1 | S)
+func (c *SRPConfig) calculate_m2(biga, premaster *big.Int, m1, key []byte) []byte {
+	if c.proofs == ProofABS {
+		//M1 is treated as an integer, dropping any leading zeros
+		var m big.Int
+		m.SetBytes(m1)
+		t := c.hashable(premaster, false)
+		defer wipe_bytes(t)
+		return c.digest(c.hashable(biga, false), c.hashable(&m, false), t)
+	}
+
+	return c.digest(c.hashable(biga, false), m1, key)
+}
+
+// Returns true if n is congruent to 0 mod N. Public values for which
+// this is true must be rejected.
+func (c *SRPConfig) is_zero(n *big.Int) bool {
+	return new(big.Int).Mod(n, &c.gp.N).Sign() == 0
+}
+
+func proofs_equal(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
@@ -2,8 +2,11 @@ package libgosrp
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
+	"errors"
 	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -154,18 +157,22 @@ func TestChallengeResponse(t *testing.T) {
 
 	config.abgen = testbgen
 
-	_, err = s.New(v, config)
+	//v was made with another password hash, which B doesn't depend
+	//on, so it's used as a record from before profiles were kept
+	legacy := v
+	legacy.Version = 0
+	_, err = s.New(legacy, config)
 
 	if err != nil {
 		t.Error("Error: ", err)
 	}
 
-	cr, err := s.ChallengeResponse()
+	cr, err := s.Challenge()
 
 	if err != nil {
 		t.Error("Error: ", err)
 	} else {
-		t.Log("JSON encoded challenge response containing s and B: ", cr)
+		t.Logf("Challenge containing s and B: %X %X", cr.Salt, cr.B)
 	}
 
 	littleb.SetString("E487CB59D31AC550471E81F00F6928E01DDA08E974A004F49E61F5D105284D20", 16)
@@ -180,3 +187,191 @@ func TestChallengeResponse(t *testing.T) {
 		t.Errorf("Error: bigb incorrect. \nExpected: %X\nGot: %X", bigb.Bytes(), s.bigb.Bytes())
 	}
 }
+
+func TestVerifierRecord(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+
+	var tmpv Verifier
+	_, err := tmpv.New("alice", "password123", 16, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tmpv.Group != config.gp.Fingerprint() || tmpv.Profile != ProfileRFC5054 || tmpv.Version != VerifierVersion || tmpv.Created.IsZero() {
+		t.Errorf("Error: verifier record incomplete: %v %v %v %v", tmpv.Group, tmpv.Profile, tmpv.Version, tmpv.Created)
+	}
+
+	other, _ := GetProfile(ProfileNimbus)
+	if _, err = new(SRPSession).New(tmpv, other); err == nil {
+		t.Error("Error: session accepted a verifier from another profile.")
+	}
+
+	adapted, err := tmpv.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = new(SRPSession).New(tmpv, adapted); err != nil {
+		t.Error(err)
+	}
+
+	legacy := tmpv
+	legacy.Version = 0
+	if _, err = new(SRPSession).New(legacy, other); err != nil {
+		t.Error("Error: version 0 verifier rejected: ", err)
+	}
+
+	unnamed := tmpv
+	unnamed.Profile = ""
+	if _, err = new(SRPSession).New(unnamed, config); err == nil {
+		t.Error("Error: session accepted a verifier without a profile.")
+	}
+
+	//configs set up by hand are told apart by their password hash
+	gp, _ := GetGroupParameters(2048)
+	custom := new(SRPConfig).New(gp, sha1hash, RandomBytes)
+	if _, err = tmpv.New("alice", "password123", 16, custom); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(tmpv.Profile, customProfile) {
+		t.Errorf("Error: hand-built config recorded profile %q.", tmpv.Profile)
+	}
+
+	if _, err = new(SRPSession).New(tmpv, new(SRPConfig).New(gp, sha1hash, RandomBytes)); err != nil {
+		t.Error("Error: verifier rejected by an identical config:", err)
+	}
+
+	if _, err = new(SRPSession).New(tmpv, new(SRPConfig).New(gp, testh, RandomBytes)); err == nil {
+		t.Error("Error: session accepted a verifier from another password hash.")
+	}
+
+	if _, err = tmpv.Config(); err == nil {
+		t.Error("Error: config made for a hand-built verifier.")
+	}
+}
+
+func TestSessionClose(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+
+	var tmpv Verifier
+	_, err := tmpv.New("alice", "password123", 16, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csess, ssess := testhandshake(t, tmpv, "password123", config, config)
+
+	key := csess.SessionKey()
+	csess.Close()
+	ssess.Close()
+
+	if csess.a.Sign() != 0 || csess.hashed_pass.Sign() != 0 || ssess.b.Sign() != 0 || ssess.v.Sign() != 0 {
+		t.Error("Error: secrets survived Close.")
+	}
+
+	if tmpv.Verifier.Sign() == 0 {
+		t.Error("Error: Close wiped the caller's verifier.")
+	}
+
+	if csess.SessionKey() != nil || ssess.SessionKey() != nil || bytes.Equal(key, make([]byte, len(key))) {
+		t.Error("Error: session keys not cleared, or the caller's copy was.")
+	}
+
+	if _, err = csess.Hello(); err == nil {
+		t.Error("Error: closed client session was still usable.")
+	}
+
+	if _, err = ssess.Proof(); err == nil {
+		t.Error("Error: closed server session was still usable.")
+	}
+
+	//failed handshakes close the session
+	ssess, _ = new(SRPSession).New(tmpv, config)
+	if _, ok := ssess.ReadHello(ClientHello{"alice", config.element(&config.gp.N)}).(ErrorIllegalParameter); !ok {
+		t.Fatal("Error: server accepted A = N.")
+	}
+
+	if ssess.b.Sign() != 0 || ssess.v.Sign() != 0 || !ssess.closed {
+		t.Error("Error: server session not wiped after failure.")
+	}
+}
+
+func TestReadHelloChecks(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+
+	var tmpv Verifier
+	if _, err := tmpv.New("alice", "password123", 16, config); err != nil {
+		t.Fatal(err)
+	}
+
+	csess, _ := new(SRPClientSession).New("mallory", config)
+	hello, _ := csess.Hello()
+
+	ssess, _ := new(SRPSession).New(tmpv, config)
+	if _, ok := ssess.ReadHello(hello).(ErrorUsernameMismatch); !ok {
+		t.Error("Error: server accepted a hello for another user.")
+	}
+
+	csess, _ = new(SRPClientSession).New("alice", config)
+	hello, _ = csess.Hello()
+
+	ssess, _ = new(SRPSession).New(tmpv, config)
+	if err := ssess.ReadHello(hello); err != nil {
+		t.Fatal(err)
+	}
+
+	key := ssess.SessionKey()
+	if _, ok := ssess.ReadHello(hello).(ErrorSessionState); !ok {
+		t.Error("Error: server read a second hello.")
+	}
+
+	if !bytes.Equal(key, ssess.SessionKey()) {
+		t.Error("Error: second hello changed the session key.")
+	}
+}
+
+func TestSessionContext(t *testing.T) {
+	gp, _ := GetGroupParameters(2048)
+	config := new(SRPConfig).New(gp, H, RandomBytes)
+	config.SetContextHash(HContext)
+
+	var v Verifier
+	if _, err := v.NewContext(context.Background(), "alice", "password123", 16, config); err != nil {
+		t.Fatal(err)
+	}
+
+	testhandshake(t, v, "password123", config, config)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := new(Verifier).NewContext(cancelled, "alice", "password123", 16, config); !errors.Is(err, context.Canceled) {
+		t.Error("Error: verifier made despite a cancelled context: ", err)
+	}
+
+	if _, err := new(SRPSession).NewContext(cancelled, v, config); !errors.Is(err, context.Canceled) {
+		t.Error("Error: server session made despite a cancelled context: ", err)
+	}
+
+	if _, err := new(SRPClientSession).NewContext(cancelled, "alice", config); !errors.Is(err, context.Canceled) {
+		t.Error("Error: client session made despite a cancelled context: ", err)
+	}
+
+	csess, _ := new(SRPClientSession).New("alice", config)
+	ssess, _ := new(SRPSession).New(v, config)
+	hello, _ := csess.Hello()
+	challenge, _ := ssess.Challenge()
+
+	if err := ssess.ReadHelloContext(cancelled, hello); !errors.Is(err, context.Canceled) {
+		t.Error("Error: server read the hello despite a cancelled context: ", err)
+	}
+
+	if err := csess.ReadChallengeContext(cancelled, challenge, "password123"); !errors.Is(err, context.Canceled) {
+		t.Error("Error: client read the challenge despite a cancelled context: ", err)
+	}
+
+	if !ssess.closed || !csess.closed || csess.session_key != nil || ssess.session_key != nil {
+		t.Error("Error: sessions not closed after cancellation.")
+	}
+}
@@ -1,8 +1,7 @@
 package libgosrp
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"math/big"
 )
 
@@ -10,84 +9,267 @@ type SRPSession struct {
 	// pointer to instance of server where
 	// the session is held
 	config      *SRPConfig
-	i           string //username
+	i           string  //username
 	s, v        big.Int //salt, verifier
 	b           big.Int //secret ephemeral value
 	biga, bigb  big.Int //public ephemeral value
-	session_key big.Int
+	session_key []byte
+	m1, m2      []byte //client and server proofs
+	verified    bool   //client proof accepted
+	closed      bool
+	transcript  *Transcript
 }
 
-func (s *SRPSession) ReadChallenge(jsonIA string) error {
-	if err := s.config.check_init(); err != nil {
+// Reads the client's username and public ephemeral value A, and
+// derives the session key. The username must be the verifier's, and
+// only one hello is read per session.
+func (s *SRPSession) ReadHello(hello ClientHello) error {
+	return s.ReadHelloContext(context.Background(), hello)
+}
+
+// As ReadHello, but gives up with ctx.Err() once ctx is done, closing
+// the session.
+func (s *SRPSession) ReadHelloContext(ctx context.Context, hello ClientHello) error {
+	if err := s.check_open(); err != nil {
+		return err
+	}
+
+	if s.session_key != nil {
+		return ErrorSessionState("ReadHello may only be called once")
+	}
+
+	if hello.I != s.i {
+		s.Close()
+		return ErrorUsernameMismatch(hello.I)
+	}
+
+	biga := s.config.decode(hello.A)
+	if s.config.is_zero(&biga) {
+		s.Close()
+		return ErrorIllegalParameter("A")
+	}
+
+	s.biga = biga
+
+	//S = (Av^u) ^ b
+	u := s.config.calculate_u(&s.biga, &s.bigb)
+	gp := s.config.gp
+
+	if err := ctx.Err(); err != nil {
+		s.Close()
+		return err
+	}
+
+	base := s.config.exp_secret(&s.v, &u, u.BitLen())
+	defer wipe(&base)
+	if err := ctx.Err(); err != nil {
+		s.Close()
 		return err
 	}
 
+	base.Mul(&base, &s.biga)
+	base.Mod(&base, &gp.N)
+	premaster := s.config.exp_secret(&base, &s.b, 8*ephemeralLen)
+	defer wipe(&premaster)
+
+	s.session_key = s.config.calculate_session_key(&premaster)
+	s.m1 = s.config.calculate_m1(s.i, &s.s, &s.biga, &s.bigb, &premaster, s.session_key)
+	s.m2 = s.config.calculate_m2(&s.biga, &premaster, s.m1, s.session_key)
+
+	if t := s.transcript; t != nil {
+		t.record_text("I", s.i, false)
+		t.record("A", &s.biga, false)
+		t.record("u", &u, false)
+		t.record("S", &premaster, true)
+		t.record_bytes("K", s.session_key, true)
+		t.record_bytes("M1", s.m1, false)
+		t.record_bytes("M2", s.m2, false)
+	}
+
 	return nil
 }
 
 func (s *SRPSession) New(v Verifier, config *SRPConfig) (*SRPSession, error) {
+	return s.NewContext(context.Background(), v, config)
+}
+
+// As New, but gives up with ctx.Err() if ctx is done before B is
+// computed.
+func (s *SRPSession) NewContext(ctx context.Context, v Verifier, config *SRPConfig) (*SRPSession, error) {
 	if err := config.check_init(); err != nil {
 		return new(SRPSession), err
 	}
 
+	if err := v.Check(config); err != nil {
+		return new(SRPSession), err
+	}
+
+	if v.Pepper != "" {
+		if config.pepper == nil {
+			return new(SRPSession), ErrorUnknownPepper(v.Pepper)
+		}
+
+		if err := config.pepper.Open(&v, config); err != nil {
+			return new(SRPSession), err
+		}
+	}
+
 	var err error
-	s.b, err = config.abgen(64)
+	s.b, err = config.abgen(ephemeralLen)
+	if err == nil {
+		err = ctx.Err()
+	}
+
 	if err != nil {
+		wipe(&s.b)
 		return new(SRPSession), err
 	}
 
 	//Initialize SRPSession fields.
-	//s.I should be set in ReadChallenge
+	//ReadHello checks the client's I against the verifier's
 	s.config = config
+	s.i = v.I
 	s.s = v.Salt
-	s.v = v.Verifier
+	//a copy, as Close wipes it and v's words may be the caller's
+	s.v.Set(&v.Verifier)
 	s.bigb = s.calculate_bigb(s.b)
+	s.record_setup()
 
 	return s, nil
 }
 
-func (s *SRPSession) ChallengeResponse() (string, error) {
-	if err := s.config.check_init(); err != nil {
-		return "", err
+// Records the session's inputs and intermediate values in t, for
+// debugging interoperability. Call before New to include the values
+// New computes. The transcript holds secrets, which Close doesn't
+// wipe; see Transcript.Redact().
+func (s *SRPSession) SetTranscript(t *Transcript) {
+	s.transcript = t
+	if t != nil {
+		t.Role = "server"
+	}
+
+	if s.config != nil {
+		s.record_setup()
+	}
+}
+
+func (s *SRPSession) record_setup() {
+	t := s.transcript
+	if t == nil {
+		return
 	}
 
-	var cr ChallengeResponse
+	t.config = s.config
+	t.Profile = s.config.Profile()
+
+	k := s.config.calculate_k()
+	t.record("N", &s.config.gp.N, false)
+	t.record("g", &s.config.gp.G, false)
+	t.record("s", &s.s, false)
+	t.record("v", &s.v, true)
+	t.record("k", &k, false)
+	t.record("b", &s.b, true)
+	t.record("B", &s.bigb, false)
+}
+
+// Returns the salt and public ephemeral value B to be sent to the
+// client.
+func (s *SRPSession) Challenge() (ServerChallenge, error) {
+	if err := s.check_open(); err != nil {
+		return ServerChallenge{}, err
+	}
 
 	//Populate message from server.
-	cr.Salt = fmt.Sprintf("%X", s.s.Bytes())
-	cr.B = fmt.Sprintf("%X", s.bigb.Bytes())
+	return ServerChallenge{s.config.element(&s.s), s.config.element(&s.bigb)}, nil
+}
 
-	output, err := json.MarshalIndent(cr, "", "    ")
-	
-	if err != nil {
-		return "", err
+// Checks the client's proof M1. Returns ErrorBadProof if the client
+// did not derive the same session key.
+func (s *SRPSession) ReadProof(p ClientProof) error {
+	if err := s.check_open(); err != nil {
+		return err
+	}
+
+	if s.session_key == nil {
+		return ErrorSessionState("ReadHello must be called before ReadProof")
+	}
+
+	if !proofs_equal(p.M1, s.m1) {
+		s.Close()
+		return ErrorBadProof("client")
 	}
 
-	return string(output), nil
+	s.verified = true
+	return nil
 }
 
-func (s *SRPSession) calulate_k() big.Int {
-	var Ng []byte
-	gp := s.config.gp
+// Returns the server's proof M2. Only available once the client's
+// proof has been accepted.
+func (s *SRPSession) Proof() (ServerProof, error) {
+	if err := s.check_open(); err != nil {
+		return ServerProof{}, err
+	}
 
-	if s.config.pad_values {
-		Ng = append(gp.N.Bytes(), Pad(len(gp.N.Bytes()), gp.G.Bytes())...)
-	} else {
-		Ng = append(gp.N.Bytes(), gp.G.Bytes()...)
+	if !s.verified {
+		return ServerProof{}, ErrorSessionState("client proof has not been accepted")
 	}
 
-	k := s.config.h(Ng, make([]byte, 0))
+	return ServerProof{append([]byte(nil), s.m2...)}, nil
+}
 
-	return k
+// Returns a copy of the shared session key K, or nil if it has not
+// been derived.
+func (s *SRPSession) SessionKey() []byte {
+	if s.session_key == nil {
+		return nil
+	}
+
+	return append([]byte(nil), s.session_key...)
+}
+
+// Overwrites the session's secrets: b, the session key and both
+// proofs. The session can't be used afterwards. Sessions close
+// themselves when the handshake fails.
+func (s *SRPSession) Close() error {
+	wipe(&s.b)
+	wipe(&s.v)
+	wipe_bytes(s.session_key)
+	wipe_bytes(s.m1)
+	wipe_bytes(s.m2)
+
+	s.session_key, s.m1, s.m2 = nil, nil, nil
+	s.verified = false
+	s.closed = true
+	return nil
+}
+
+func (s *SRPSession) check_open() error {
+	if s.closed {
+		return ErrorSessionState("session is closed")
+	}
+
+	//check_init returns a pointer, which mustn't become a non-nil
+	//error
+	if err := s.config.check_init(); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (s *SRPSession) calculate_bigb(b big.Int) big.Int {
 	gp := s.config.gp
 
 	//calculate B = kv+g^b
-	B := s.calulate_k()
+	B := s.config.calculate_k()
 	B.Mul(&B, &s.v)
-	B.Add(&B, new(big.Int).Exp(&gp.G, &s.b, &gp.N))
+	gb := s.config.exp_g(&b, 8*ephemeralLen)
+	B.Add(&B, &gb)
+	wipe(&gb)
+
+	if s.config.reduce_bigb {
+		B.Mod(&B, &gp.N)
+	}
 
 	return B
 }
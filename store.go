@@ -0,0 +1,97 @@
+package libgosrp
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// Persists verifiers by username.
+type VerifierStore interface {
+	// Returns the verifier for username i, or ErrorUnknownUser.
+	Get(ctx context.Context, i string) (Verifier, error)
+	// Stores v, replacing any verifier with the same username.
+	Put(ctx context.Context, v Verifier) error
+	// Removes the verifier for username i, or returns
+	// ErrorUnknownUser.
+	Delete(ctx context.Context, i string) error
+	// Returns every username in the store, sorted.
+	List(ctx context.Context) ([]string, error)
+}
+
+type ErrorUnknownUser string
+
+func (e ErrorUnknownUser) Error() string {
+	return fmt.Sprintf("No verifier stored for user %q.", string(e))
+}
+
+// A VerifierStore held in memory, for tests and for programs that load
+// their verifiers from elsewhere. Safe for concurrent use.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	verifiers map[string]Verifier
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{verifiers: make(map[string]Verifier)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, i string) (Verifier, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.verifiers[i]
+	if !ok {
+		return Verifier{}, ErrorUnknownUser(i)
+	}
+
+	return v.clone(), nil
+}
+
+func (m *MemoryStore) Put(ctx context.Context, v Verifier) error {
+	if v.I == "" {
+		return new(EmptyUsernameError)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.verifiers[v.I] = v.clone()
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, i string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.verifiers[i]; !ok {
+		return ErrorUnknownUser(i)
+	}
+
+	delete(m.verifiers, i)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.verifiers))
+	for i := range m.verifiers {
+		names = append(names, i)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Returns a copy of v that shares no memory with it.
+func (v *Verifier) clone() Verifier {
+	c := *v
+	c.Salt, c.Verifier = big.Int{}, big.Int{}
+	c.Salt.Set(&v.Salt)
+	c.Verifier.Set(&v.Verifier)
+	return c
+}
@@ -0,0 +1,58 @@
+package libgosrp
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "verifiers.json")
+	config, _ := GetProfile(ProfileRFC5054)
+
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []string{"carol", "alice", "bob"} {
+		var v Verifier
+		if _, err = v.New(i, "password123", 16, config); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = store.Put(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = store.Delete(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.Delete(ctx, "bob").(ErrorUnknownUser); !ok {
+		t.Error("Error: deleted a user twice.")
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, _ := reopened.List(ctx)
+	if !reflect.DeepEqual(names, []string{"alice", "carol"}) {
+		t.Errorf("Error: wrong users after reopening: %v", names)
+	}
+
+	v, err := reopened.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testhandshake(t, v, "password123", config, config)
+
+	if _, err = reopened.Get(ctx, "bob"); err == nil {
+		t.Error("Error: deleted user still stored.")
+	}
+}
@@ -0,0 +1,77 @@
+// Writes nimbus.json from a handshake between Nimbus SRP's own client
+// and server sessions (com.nimbusds:srp6a), with the private values
+// fixed so that the Go test can replay it.
+//
+// Usage: see regen.sh, which passes the library's version as the only
+// argument
+import com.nimbusds.srp6.*;
+
+import java.io.FileWriter;
+import java.math.BigInteger;
+import java.nio.charset.StandardCharsets;
+import java.security.SecureRandom;
+
+public class GenNimbus {
+	static final String I = "alice";
+	static final String P = "password123";
+	static final String SALT = "BEB25379D1A8581EB5A727673A2441EE";
+	static final BigInteger A = new BigInteger("60975527035CF2AD1989806F0407210BC81EDC04E2762A56AFD529DDDA2D4393", 16);
+	static final BigInteger B = new BigInteger("E487CB59D31AC550471E81F00F6928E01DDA08E974A004F49E61F5D105284D20", 16);
+
+	static SRP6Routines fixed(final BigInteger value) {
+		return new SRP6Routines() {
+			@Override
+			public BigInteger generatePrivateValue(BigInteger N, SecureRandom random) {
+				return value;
+			}
+		};
+	}
+
+	// integers as whole bytes, digests at their full length
+	static String hex(BigInteger n) {
+		String s = n.toString(16).toUpperCase();
+		return s.length() % 2 == 1 ? "0" + s : s;
+	}
+
+	static String digest(BigInteger n) {
+		return String.format("%64s", n.toString(16).toUpperCase()).replace(' ', '0');
+	}
+
+	static String digest(byte[] b) {
+		return digest(new BigInteger(1, b));
+	}
+
+	public static void main(String[] args) throws Exception {
+		SRP6CryptoParams config = SRP6CryptoParams.getInstance(2048, "SHA-256");
+		byte[] salt = BigIntegerUtils.bigIntegerToBytes(new BigInteger(SALT, 16));
+		BigInteger v = new SRP6VerifierGenerator(config).generateVerifier(salt, I, P);
+
+		SRP6ClientSession client = new SRP6ClientSession(0, fixed(A));
+		SRP6ServerSession server = new SRP6ServerSession(config, 0, fixed(B));
+
+		client.step1(I, P);
+		BigInteger bigB = server.step1(I, new BigInteger(1, salt), v);
+		SRP6ClientCredentials credentials = client.step2(config, new BigInteger(1, salt), bigB);
+		BigInteger M2 = server.step2(credentials.A, credentials.M1);
+		client.step3(M2);
+
+		String json = "{\n" +
+			"    \"generator\": \"com.nimbusds:srp6a " + args[0] + "\",\n" +
+			"    \"I\": \"" + I + "\",\n" +
+			"    \"P\": \"" + P + "\",\n" +
+			"    \"salt\": \"" + SALT + "\",\n" +
+			"    \"a\": \"" + hex(A) + "\",\n" +
+			"    \"b\": \"" + hex(B) + "\",\n" +
+			"    \"v\": \"" + hex(v) + "\",\n" +
+			"    \"A\": \"" + hex(credentials.A) + "\",\n" +
+			"    \"B\": \"" + hex(bigB) + "\",\n" +
+			"    \"K\": \"" + digest(server.getSessionKeyHash()) + "\",\n" +
+			"    \"M1\": \"" + digest(credentials.M1) + "\",\n" +
+			"    \"M2\": \"" + digest(M2) + "\"\n" +
+			"}\n";
+
+		try (FileWriter out = new FileWriter("nimbus.json", StandardCharsets.UTF_8)) {
+			out.write(json);
+		}
+	}
+}
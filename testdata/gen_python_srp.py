@@ -0,0 +1,49 @@
+#!/usr/bin/env python3
+# Writes python-srp.json from a handshake between python-srp's own
+# User and Verifier, with the private values fixed so that the Go test
+# can replay it.
+#
+# Usage: see regen.sh
+import importlib.metadata
+import json
+
+import srp
+
+I = "alice"
+#H(I | ":" | P) starts with a zero byte, which python-srp drops
+P = "password60"
+a = bytes.fromhex("60975527035CF2AD1989806F0407210BC81EDC04E2762A56AFD529DDDA2D4393")
+b = bytes.fromhex("E487CB59D31AC550471E81F00F6928E01DDA08E974A004F49E61F5D105284D20")
+params = {"hash_alg": srp.SHA1, "ng_type": srp.NG_2048}
+
+srp.rfc5054_enable(False)
+
+#the Go side keeps salts as integers, so one with a leading zero byte
+#wouldn't survive the trip
+salt = b"\0"
+while salt[0] == 0:
+    salt, v = srp.create_salted_verification_key(I, P, **params)
+
+user = srp.User(I, P, bytes_a=a, **params)
+_, A = user.start_authentication()
+
+verifier = srp.Verifier(I, salt, v, A, bytes_b=b, **params)
+s, B = verifier.get_challenge()
+
+M1 = user.process_challenge(s, B)
+M2 = verifier.verify_session(M1)
+user.verify_session(M2)
+assert user.authenticated() and verifier.authenticated()
+
+transcript = {
+    "generator": "srp " + importlib.metadata.version("srp"),
+    "I": I, "P": P, "salt": s.hex().upper(),
+    "a": a.hex().upper(), "b": b.hex().upper(),
+    "v": v.hex().upper(), "A": A.hex().upper(), "B": B.hex().upper(),
+    "K": verifier.get_session_key().hex().upper(),
+    "M1": M1.hex().upper(), "M2": M2.hex().upper(),
+}
+
+with open("python-srp.json", "w") as f:
+    json.dump(transcript, f, indent=4)
+    f.write("\n")
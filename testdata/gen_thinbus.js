@@ -0,0 +1,62 @@
+// Writes thinbus.json from a handshake between Thinbus's JavaScript
+// client and server sessions (thinbus-srp on npm), with the private
+// values fixed so that the Go test can replay it.
+//
+// Usage: see regen.sh
+const crypto = require("crypto");
+const fs = require("fs");
+
+const N = BigInt("0x" +
+    "AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050" +
+    "A37329CBB4A099ED8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50" +
+    "E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B8" +
+    "55F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773B" +
+    "CA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748" +
+    "544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6" +
+    "AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB6" +
+    "94B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73");
+const g = 2n;
+// k = H(hex(N) | hex(g)), as the Java HexHashedRoutines compute it
+const k = crypto.createHash("sha256").update(N.toString(16) + g.toString(16)).digest("hex");
+
+const Client = require("thinbus-srp/client.js")(N.toString(10), g.toString(10), k);
+const Server = require("thinbus-srp/server.js")(N.toString(10), g.toString(10), k);
+
+const I = "alice";
+// H(I | ":" | P) starts with a zero hex digit, which Thinbus drops
+const P = "password147";
+const a = "60975527035CF2AD1989806F0407210BC81EDC04E2762A56AFD529DDDA2D4393";
+const b = "E487CB59D31AC550471E81F00F6928E01DDA08E974A004F49E61F5D105284D20";
+
+const client = new Client();
+client.randomA = function () { return this.fromHex(a); };
+const server = new Server();
+server.randomB = function () { return this.fromHex(b); };
+
+// the Go side keeps salts as integers, so one with a leading zero byte
+// wouldn't survive the trip
+let salt;
+do {
+    salt = client.generateRandomSalt();
+} while (salt.startsWith("00"));
+const v = client.generateVerifier(salt, I, P);
+
+client.step1(I, P);
+const B = server.step1(I, salt, v);
+const credentials = client.step2(salt, B);
+const M2 = server.step2(credentials.A, credentials.M1);
+client.step3(M2);
+
+// integers as whole bytes, digests at their full length
+const bytes = (hex) => (hex.length % 2 ? "0" + hex : hex).toUpperCase();
+const digest = (hex) => hex.padStart(64, "0").toUpperCase();
+
+const transcript = {
+    generator: "thinbus-srp " + require("thinbus-srp/package.json").version,
+    I: I, P: P, salt: bytes(salt), a: a, b: b,
+    v: bytes(v), A: bytes(credentials.A), B: bytes(B),
+    K: digest(client.getSessionKey()),
+    M1: digest(credentials.M1), M2: digest(M2),
+};
+
+fs.writeFileSync("thinbus.json", JSON.stringify(transcript, null, 4) + "\n");
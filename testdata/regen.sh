@@ -0,0 +1,32 @@
+#!/bin/sh
+# Rewrites python-srp.json, thinbus.json and nimbus.json from real
+# handshakes between each library's own client and server, at the
+# versions pinned below. Needs python3, node and a JDK, and network
+# access to PyPI, npm and Maven Central. Each transcript records the
+# library and version that wrote it in its "generator" field.
+#
+# Usage: cd testdata && ./regen.sh
+set -eu
+
+PYTHON_SRP=1.0.21
+THINBUS=1.8.0
+NIMBUS=2.1.0
+
+here=$(pwd)
+work=$(mktemp -d)
+trap 'rm -rf "$work"' EXIT
+
+python3 -m venv "$work/venv"
+"$work/venv/bin/pip" install --quiet "srp==$PYTHON_SRP"
+"$work/venv/bin/python" gen_python_srp.py
+
+(cd "$work" && npm install --silent --no-save "thinbus-srp@$THINBUS")
+NODE_PATH="$work/node_modules" node gen_thinbus.js
+
+jar="$work/srp6a-$NIMBUS.jar"
+curl -sSfo "$jar" "https://repo1.maven.org/maven2/com/nimbusds/srp6a/$NIMBUS/srp6a-$NIMBUS.jar"
+javac -d "$work" -cp "$jar" GenNimbus.java
+java -cp "$jar:$work" GenNimbus "$NIMBUS"
+
+cd "$here"
+grep -h '"generator"' python-srp.json thinbus.json nimbus.json
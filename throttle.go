@@ -0,0 +1,342 @@
+package libgosrp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Limits online password guessing by slowing down, then locking out,
+// usernames and client addresses after failed proofs. Give it to a
+// Server with SetThrottle. Failed proofs for unknown users count like
+// any others, so throttling doesn't reveal which users exist.
+type Throttle struct {
+	Counter ThrottleCounter
+	User    ThrottlePolicy
+	Addr    ThrottlePolicy
+	// Called when a username or address is locked out. Lockout.User
+	// may name a user who doesn't exist.
+	OnLockout func(ctx context.Context, l Lockout)
+}
+
+// How failures are punished. After Free failures, each further
+// failure makes the next attempt wait Delay, doubling with every
+// failure up to MaxDelay. After LockoutAfter failures, attempts are
+// refused for LockoutFor; 0 disables lockouts. Failures are forgotten
+// once there have been none for Window, which ends lockouts too, so
+// Window should be at least LockoutFor.
+type ThrottlePolicy struct {
+	Free         int
+	Delay        time.Duration
+	MaxDelay     time.Duration
+	LockoutAfter int
+	LockoutFor   time.Duration
+	Window       time.Duration
+}
+
+// Failed proofs recorded for a username or address.
+type Failures struct {
+	Count int
+	Last  time.Time
+	//time of the failure before Last, if Add returned this and it
+	//wasn't forgotten
+	Previous time.Time
+}
+
+type Lockout struct {
+	//one of User and Addr is set
+	User     string
+	Addr     string
+	Failures int
+	Until    time.Time
+}
+
+// Keeps failure counts for a Throttle. Keys are usernames and
+// addresses, prefixed with "user:" and "addr:".
+type ThrottleCounter interface {
+	// Returns the failures recorded for key.
+	Get(ctx context.Context, key string) (Failures, error)
+	// Records a failure for key at time at, first forgetting earlier
+	// failures if the last was before since, and returns the new
+	// count, with the time of the last failure before this one in
+	// Previous. Concurrent calls must each see the other's failure.
+	Add(ctx context.Context, key string, at, since time.Time) (Failures, error)
+	// Takes back the failure added for key at time at, as when the
+	// attempt it was reserved for is refused or succeeds. If no
+	// failure has been added since, Last goes back to Previous.
+	Refund(ctx context.Context, key string, at time.Time) error
+	// Forgets the failures for key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Returned instead of a challenge or a proof check while a username
+// or address must wait. The value is how long to wait.
+type ErrorThrottled time.Duration
+
+func (e ErrorThrottled) Error() string {
+	return fmt.Sprintf("Too many failed logins: try again in %v.", time.Duration(e).Round(time.Second))
+}
+
+// Returns a throttle using counter, allowing users 3 failures and
+// addresses 10 before delays start at a second, and locking out users
+// after 20 failures and addresses after 100, for 15 minutes.
+func NewThrottle(counter ThrottleCounter) *Throttle {
+	return &Throttle{
+		Counter: counter,
+		User:    ThrottlePolicy{3, time.Second, time.Minute, 20, 15 * time.Minute, 24 * time.Hour},
+		Addr:    ThrottlePolicy{10, time.Second, time.Minute, 100, 15 * time.Minute, time.Hour},
+	}
+}
+
+type remoteAddrKey struct{}
+
+// Returns a context carrying the client's network address, for the
+// Server to throttle by. A port, if any, is ignored.
+func WithRemoteAddr(ctx context.Context, addr string) context.Context {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	return context.WithValue(ctx, remoteAddrKey{}, addr)
+}
+
+// The client address from WithRemoteAddr, or "".
+func RemoteAddr(ctx context.Context) string {
+	addr, _ := ctx.Value(remoteAddrKey{}).(string)
+	return addr
+}
+
+// How long after f the next attempt must wait under p, from now.
+func (p *ThrottlePolicy) wait(f Failures, now time.Time) time.Duration {
+	if f.Count == 0 || now.Sub(f.Last) > p.Window {
+		return 0
+	}
+
+	var delay time.Duration
+	if p.LockoutAfter > 0 && f.Count >= p.LockoutAfter {
+		delay = p.LockoutFor
+	} else if f.Count > p.Free {
+		delay = p.MaxDelay
+		if shift := f.Count - p.Free - 1; shift < 32 && p.Delay<<shift < p.MaxDelay {
+			delay = p.Delay << shift
+		}
+	}
+
+	return f.Last.Add(delay).Sub(now)
+}
+
+// Returns the keys for user i and the address in ctx, with their
+// policies.
+func (t *Throttle) keys(ctx context.Context, i string) ([]string, []*ThrottlePolicy) {
+	keys, policies := []string{"user:" + i}, []*ThrottlePolicy{&t.User}
+	if addr := RemoteAddr(ctx); addr != "" {
+		keys, policies = append(keys, "addr:"+addr), append(policies, &t.Addr)
+	}
+
+	return keys, policies
+}
+
+// Returns ErrorThrottled if user i or the address in ctx must wait.
+func (t *Throttle) check(ctx context.Context, i string) error {
+	now := time.Now()
+	keys, policies := t.keys(ctx, i)
+
+	var wait time.Duration
+	for n, key := range keys {
+		f, err := t.Counter.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if w := policies[n].wait(f, now); w > wait {
+			wait = w
+		}
+	}
+
+	if wait > 0 {
+		return ErrorThrottled(wait)
+	}
+
+	return nil
+}
+
+// A proof attempt, counted as a failure of user i and the address
+// until it's known to have succeeded.
+type reservation struct {
+	i        string
+	at       time.Time
+	keys     []string
+	failures []Failures
+}
+
+// Counts a failure for user i and the address in ctx ahead of
+// checking a proof, returning ErrorThrottled, with the failure taken
+// back, if they must wait. The wait is judged on the failures before
+// this one, so of several attempts made at once, those beyond what
+// the policies allow are refused.
+func (t *Throttle) reserve(ctx context.Context, i string) (*reservation, error) {
+	now := time.Now()
+	keys, policies := t.keys(ctx, i)
+	r := &reservation{i: i, at: now}
+
+	var wait time.Duration
+	for n, key := range keys {
+		f, err := t.Counter.Add(ctx, key, now, now.Add(-policies[n].Window))
+		if err != nil {
+			t.refund(ctx, r)
+			return nil, err
+		}
+		r.keys, r.failures = append(r.keys, key), append(r.failures, f)
+
+		before := Failures{Count: f.Count - 1, Last: f.Previous}
+		if w := policies[n].wait(before, now); w > wait {
+			wait = w
+		}
+	}
+
+	if wait > 0 {
+		if err := t.refund(ctx, r); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrorThrottled(wait)
+	}
+
+	return r, nil
+}
+
+// Takes back the failures counted by r.
+func (t *Throttle) refund(ctx context.Context, r *reservation) error {
+	var err error
+	for _, key := range r.keys {
+		if rerr := t.Counter.Refund(ctx, key, r.at); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+
+	return err
+}
+
+// Keeps the failures counted by r, as the proof was wrong, and
+// returns the lockouts they caused.
+func (t *Throttle) fail(ctx context.Context, r *reservation) []Lockout {
+	_, policies := t.keys(ctx, r.i)
+
+	var lockouts []Lockout
+	for n, f := range r.failures {
+		p := policies[n]
+		if p.LockoutAfter > 0 && f.Count >= p.LockoutAfter {
+			l := Lockout{Failures: f.Count, Until: f.Last.Add(p.LockoutFor)}
+			if n == 0 {
+				l.User = r.i
+			} else {
+				l.Addr = RemoteAddr(ctx)
+			}
+
+			if t.OnLockout != nil {
+				t.OnLockout(ctx, l)
+			}
+			lockouts = append(lockouts, l)
+		}
+	}
+
+	return lockouts
+}
+
+// Takes back the failures counted by r and forgets those of its user
+// after a successful login. The address keeps its earlier count, so
+// one account an attacker can log in to doesn't reset the count for
+// guesses at others.
+func (t *Throttle) succeed(ctx context.Context, r *reservation) error {
+	if err := t.refund(ctx, r); err != nil {
+		return err
+	}
+
+	return t.Counter.Reset(ctx, "user:"+r.i)
+}
+
+// A ThrottleCounter held in memory, for a single server. Failures are
+// dropped once they're too old to count. Safe for concurrent use.
+type MemoryCounter struct {
+	mu       sync.Mutex
+	failures map[string]memoryFailures
+	pruned   time.Time
+}
+
+type memoryFailures struct {
+	Failures
+	expires time.Time
+}
+
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{failures: make(map[string]memoryFailures)}
+}
+
+func (m *MemoryCounter) Get(ctx context.Context, key string) (Failures, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.failures[key].Failures, nil
+}
+
+func (m *MemoryCounter) Add(ctx context.Context, key string, at, since time.Time) (Failures, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	//drops expired keys, at most once a minute
+	if at.Sub(m.pruned) >= time.Minute {
+		for k, f := range m.failures {
+			if f.expires.Before(at) {
+				delete(m.failures, k)
+			}
+		}
+		m.pruned = at
+	}
+
+	f := m.failures[key]
+	if f.Last.Before(since) {
+		f.Count = 0
+		f.Last = time.Time{}
+	}
+
+	f.Count++
+	f.Previous = f.Last
+	f.Last = at
+	//a failure counts until as long after it as since is before it
+	f.expires = at.Add(at.Sub(since))
+	m.failures[key] = f
+	return f.Failures, nil
+}
+
+func (m *MemoryCounter) Refund(ctx context.Context, key string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.failures[key]
+	if !ok {
+		return nil
+	}
+
+	f.Count--
+	if f.Last.Equal(at) {
+		f.Last, f.Previous = f.Previous, time.Time{}
+	}
+
+	if f.Count <= 0 {
+		delete(m.failures, key)
+	} else {
+		m.failures[key] = f
+	}
+
+	return nil
+}
+
+func (m *MemoryCounter) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
@@ -0,0 +1,208 @@
+package libgosrp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Runs a login from addr, returning the first error.
+func testattempt(server *Server, config *SRPConfig, addr, i, p string) error {
+	ctx := WithRemoteAddr(context.Background(), addr)
+
+	csess, _ := new(SRPClientSession).New(i, config)
+	hello, _ := csess.Hello()
+	id, challenge, err := server.Hello(ctx, hello)
+	if err != nil {
+		return err
+	}
+
+	if err = csess.ReadChallenge(challenge, p); err != nil {
+		return err
+	}
+
+	proof, _ := csess.Proof()
+	_, err = server.Proof(ctx, id, proof)
+	return err
+}
+
+func TestThrottle(t *testing.T) {
+	server, config := testserver(t)
+
+	var lockouts []Lockout
+	throttle := NewThrottle(NewMemoryCounter())
+	throttle.User = ThrottlePolicy{Free: 1, Delay: time.Hour, MaxDelay: time.Hour, Window: time.Hour}
+	throttle.Addr = ThrottlePolicy{Free: 10, LockoutAfter: 3, LockoutFor: time.Hour, Window: time.Hour}
+	throttle.OnLockout = func(ctx context.Context, l Lockout) {
+		lockouts = append(lockouts, l)
+	}
+	server.SetThrottle(throttle)
+
+	//unknown users are throttled the same way as real ones
+	for _, i := range []string{"alice", "nobody"} {
+		addr := "192.0.2.1:1000"
+		if i == "nobody" {
+			addr = "192.0.2.2:1000"
+		}
+
+		for n, want := range []string{CodeBadProof, CodeBadProof, CodeThrottled} {
+			err := testattempt(server, config, addr, i, "wrong")
+			if err == nil || NewErrorMessage(err).Code != want {
+				t.Errorf("Error: attempt %d for %s gave %v, want %s.", n+1, i, err, want)
+			}
+		}
+	}
+
+	if len(lockouts) != 0 {
+		t.Errorf("Error: unexpected lockouts: %+v", lockouts)
+	}
+
+	//the address is locked out after guesses at different users
+	for n, i := range []string{"carol", "dave", "erin", "alice"} {
+		err := testattempt(server, config, "198.51.100.7:2000", i, "wrong")
+		if _, throttled := err.(ErrorThrottled); throttled != (n == 3) {
+			t.Errorf("Error: attempt %d from one address gave %v.", n+1, err)
+		}
+	}
+
+	if len(lockouts) != 1 || lockouts[0].Addr != "198.51.100.7" || lockouts[0].Failures != 3 {
+		t.Errorf("Error: wrong lockouts: %+v", lockouts)
+	}
+
+	//success resets the user's count
+	throttle.Counter.Reset(context.Background(), "user:alice")
+	testattempt(server, config, "203.0.113.1", "alice", "wrong")
+	if err := testattempt(server, config, "203.0.113.1", "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	if f, _ := throttle.Counter.Get(context.Background(), "user:alice"); f.Count != 0 {
+		t.Errorf("Error: %d failures left after a successful login.", f.Count)
+	}
+}
+
+func TestThrottleConcurrent(t *testing.T) {
+	server, config := testserver(t)
+
+	var mu sync.Mutex
+	var lockouts []Lockout
+	throttle := NewThrottle(NewMemoryCounter())
+	throttle.User = ThrottlePolicy{Free: 100, LockoutAfter: 5, LockoutFor: time.Hour, Window: time.Hour}
+	throttle.OnLockout = func(ctx context.Context, l Lockout) {
+		mu.Lock()
+		defer mu.Unlock()
+		lockouts = append(lockouts, l)
+	}
+	server.SetThrottle(throttle)
+
+	//every hello is answered before any proof is sent, so that the
+	//proofs all pass the check in Hello
+	const attempts = 50
+	type started struct {
+		ctx   context.Context
+		id    string
+		proof ClientProof
+	}
+	logins := make([]started, attempts)
+	for n := range logins {
+		ctx := WithRemoteAddr(context.Background(), fmt.Sprintf("192.0.2.%d:1000", n+1))
+		csess, _ := new(SRPClientSession).New("alice", config)
+		hello, _ := csess.Hello()
+		id, challenge, err := server.Hello(ctx, hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = csess.ReadChallenge(challenge, "wrong"); err != nil {
+			t.Fatal(err)
+		}
+		proof, _ := csess.Proof()
+		logins[n] = started{ctx, id, proof}
+	}
+
+	var wg sync.WaitGroup
+	var bad, throttled atomic.Int32
+	for _, l := range logins {
+		wg.Add(1)
+		go func(l started) {
+			defer wg.Done()
+			_, err := server.Proof(l.ctx, l.id, l.proof)
+			switch err.(type) {
+			case ErrorBadProof:
+				bad.Add(1)
+			case ErrorThrottled:
+				throttled.Add(1)
+			default:
+				t.Error("Error: unexpected proof error:", err)
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	if bad.Load() != 5 || throttled.Load() != attempts-5 {
+		t.Errorf("Error: %d proofs checked and %d throttled, want 5 and %d.", bad.Load(), throttled.Load(), attempts-5)
+	}
+
+	if len(lockouts) != 1 || lockouts[0].User != "alice" || lockouts[0].Failures != 5 {
+		t.Errorf("Error: wrong lockouts: %+v", lockouts)
+	}
+
+	if f, _ := throttle.Counter.Get(context.Background(), "user:alice"); f.Count != 5 {
+		t.Errorf("Error: %d failures counted for 5 wrong proofs.", f.Count)
+	}
+}
+
+func TestThrottleCounters(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "failures.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sqlCounter, err := OpenSQLCounter(ctx, db, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlCounter.Close()
+
+	for name, c := range map[string]ThrottleCounter{"memory": NewMemoryCounter(), "SQL": sqlCounter} {
+		start := time.Now().Truncate(time.Second)
+		for n := 0; n < 3; n++ {
+			c.Add(ctx, "user:alice", start.Add(time.Duration(n)*time.Second), start.Add(-time.Minute))
+		}
+
+		f, err := c.Get(ctx, "user:alice")
+		if err != nil || f.Count != 3 || !f.Last.Equal(start.Add(2*time.Second)) {
+			t.Errorf("Error: %s counter has %+v: %v", name, f, err)
+		}
+
+		//a refunded failure gives back the count and the time before it
+		at := start.Add(3 * time.Second)
+		if f, _ = c.Add(ctx, "user:alice", at, start.Add(-time.Minute)); f.Count != 4 || !f.Previous.Equal(start.Add(2*time.Second)) {
+			t.Errorf("Error: %s counter added %+v.", name, f)
+		}
+
+		c.Refund(ctx, "user:alice", at)
+		if f, _ = c.Get(ctx, "user:alice"); f.Count != 3 || !f.Last.Equal(start.Add(2*time.Second)) {
+			t.Errorf("Error: %s counter has %+v after a refund.", name, f)
+		}
+
+		//failures before since are forgotten
+		later := start.Add(time.Hour)
+		if f, _ = c.Add(ctx, "user:alice", later, later.Add(-time.Minute)); f.Count != 1 || !f.Previous.IsZero() {
+			t.Errorf("Error: %s counter kept old failures: %+v", name, f)
+		}
+
+		c.Reset(ctx, "user:alice")
+		if f, _ = c.Get(ctx, "user:alice"); f.Count != 0 {
+			t.Errorf("Error: %s counter not reset: %+v", name, f)
+		}
+	}
+}
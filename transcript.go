@@ -0,0 +1,439 @@
+package libgosrp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+// Values in the order they are computed, which is the order
+// transcripts are compared in.
+var transcriptOrder = []string{"N", "g", "I", "P", "s", "x", "v", "k", "a", "A", "b", "B", "u", "S", "K", "M1", "M2"}
+
+// Other names for the values, as printed by other implementations.
+var transcriptAliases = map[string]string{
+	"salt":        "s",
+	"username":    "I",
+	"user":        "I",
+	"password":    "P",
+	"p":           "P",
+	"G":           "g",
+	"n":           "N",
+	"premaster":   "S",
+	"key":         "K",
+	"session_key": "K",
+	"sessionkey":  "K",
+	"M":           "M1",
+	"m1":          "M1",
+	"m2":          "M2",
+	"HAMK":        "M2",
+	"hamk":        "M2",
+}
+
+// The inputs and intermediate values of one side of a handshake, for
+// finding where another implementation diverges. Transcripts hold
+// secrets, including the password; Redact() removes them.
+type Transcript struct {
+	Role    string            `json:"role,omitempty"`
+	Profile string            `json:"profile,omitempty"`
+	Values  []TranscriptValue `json:"values"`
+	//config the values were computed with, for trying variants of
+	//the hashes in Compare
+	config *SRPConfig
+}
+
+type TranscriptValue struct {
+	Name string `json:"name"`
+	//upper case hex, or text for I and P
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// Where two transcripts first differ.
+type TranscriptMismatch struct {
+	Name   string
+	Ours   string
+	Theirs string
+	//likely reason for the difference
+	Cause string
+}
+
+func (m *TranscriptMismatch) String() string {
+	return fmt.Sprintf("%s differs.\nours:   %s\ntheirs: %s\nlikely cause: %s", m.Name, m.Ours, m.Theirs, m.Cause)
+}
+
+func (t *Transcript) record_text(name, value string, secret bool) {
+	if t != nil {
+		t.set(TranscriptValue{name, value, secret})
+	}
+}
+
+func (t *Transcript) record_bytes(name string, value []byte, secret bool) {
+	if t != nil {
+		t.set(TranscriptValue{name, fmt.Sprintf("%X", value), secret})
+	}
+}
+
+func (t *Transcript) record(name string, value *big.Int, secret bool) {
+	if t != nil {
+		t.record_bytes(name, t.config.element(value), secret)
+	}
+}
+
+func (t *Transcript) set(value TranscriptValue) {
+	for i := range t.Values {
+		if t.Values[i].Name == value.Name {
+			t.Values[i] = value
+			return
+		}
+	}
+
+	t.Values = append(t.Values, value)
+}
+
+// Returns the value recorded under name, after resolving aliases.
+func (t *Transcript) Get(name string) (string, bool) {
+	name = canonicalName(name)
+	for _, v := range t.Values {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Removes every secret value: the password, x, v, a, b, S and K.
+func (t *Transcript) Redact() {
+	values := t.Values[:0]
+	for _, v := range t.Values {
+		if !v.Secret {
+			values = append(values, v)
+		}
+	}
+
+	t.Values = values
+}
+
+func canonicalName(name string) string {
+	name = strings.TrimSpace(name)
+	if alias, ok := transcriptAliases[name]; ok {
+		return alias
+	}
+
+	if alias, ok := transcriptAliases[strings.ToLower(name)]; ok {
+		return alias
+	}
+
+	return name
+}
+
+// Reads a transcript, either in the JSON form of Transcript or as
+// lines of "name = value" or "name: value", the way most
+// implementations print their values. Hex values may be in either
+// case, with or without 0x and separating spaces or colons. Lines
+// starting with # are ignored.
+func ParseTranscript(data []byte) (*Transcript, error) {
+	t := new(Transcript)
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, t); err != nil {
+			return nil, err
+		}
+
+		for i := range t.Values {
+			t.Values[i].Name = canonicalName(t.Values[i].Name)
+		}
+
+		t.restore_config()
+		return t, nil
+	}
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			return nil, ErrorMalformed(fmt.Sprintf("transcript line %d is not name = value", n+1))
+		}
+
+		name := canonicalName(line[:sep])
+		value := strings.TrimSpace(line[sep+1:])
+		if name != "I" && name != "P" {
+			value = normalizeHex(value)
+		}
+
+		t.set(TranscriptValue{Name: name, Value: value})
+	}
+
+	return t, nil
+}
+
+// Rebuilds the config of a transcript written by this package from
+// its profile and group, so Compare can try variants of its hashes.
+func (t *Transcript) restore_config() {
+	config, err := GetProfile(t.Profile)
+	if err != nil {
+		return
+	}
+
+	for _, name := range []string{"N", "g"} {
+		value, _ := t.Get(name)
+		b, err := hex.DecodeString(value)
+		if err != nil || len(b) == 0 {
+			return
+		}
+
+		if name == "N" {
+			config.gp.N = config.decode(b)
+		} else {
+			config.gp.G = config.decode(b)
+		}
+	}
+
+	t.config = config
+}
+
+func normalizeHex(value string) string {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	value = strings.NewReplacer(" ", "", ":", "", "\t", "").Replace(value)
+	return strings.ToUpper(value)
+}
+
+// Compares the transcripts value by value in the order they're
+// computed, returning the first value recorded in both that differs,
+// or nil if none do.
+func (t *Transcript) Compare(theirs *Transcript) *TranscriptMismatch {
+	for _, name := range transcriptOrder {
+		ours, ok := t.Get(name)
+		other, theirok := theirs.Get(name)
+		if !ok || !theirok {
+			continue
+		}
+
+		if name == "I" || name == "P" {
+			if ours != other {
+				return &TranscriptMismatch{name, ours, other, "the inputs differ"}
+			}
+
+			continue
+		}
+
+		a, aerr := hex.DecodeString(normalizeHex(ours))
+		b, berr := hex.DecodeString(normalizeHex(other))
+		if aerr != nil || berr != nil {
+			if ours != other {
+				return &TranscriptMismatch{name, ours, other, "one of the values is not hex"}
+			}
+
+			continue
+		}
+
+		if !bytes.Equal(a, b) {
+			return &TranscriptMismatch{name, ours, other, t.cause(name, a, b)}
+		}
+	}
+
+	return nil
+}
+
+// Guesses why value name is a in our transcript and b in theirs, all
+// earlier values being equal.
+func (t *Transcript) cause(name string, a, b []byte) string {
+	trimmedA, trimmedB := bytes.TrimLeft(a, "\x00"), bytes.TrimLeft(b, "\x00")
+	if bytes.Equal(trimmedA, trimmedB) {
+		return "padding: one side has leading zero bytes the other lacks; check whether values are padded to the length of N"
+	}
+
+	if bytes.Equal(bytes.TrimLeft(reverseCopy(b), "\x00"), trimmedA) || bytes.Equal(bytes.TrimLeft(reverseCopy(a), "\x00"), trimmedB) {
+		return "byte order: the values are byte-reversed; one side is little-endian"
+	}
+
+	if t.config != nil {
+		if variant := t.find_variant(name, b); variant != "" {
+			return "their " + name + " is " + variant
+		}
+	}
+
+	switch name {
+	case "N", "g":
+		return "the sides use different groups"
+	case "s", "a", "b":
+		return "the sides were given different values; fix the random values to compare a handshake"
+	case "A":
+		return "a or the group differs"
+	case "B":
+		return "k, v or b differs, or B is reduced mod N on one side only"
+	case "x", "v":
+		return "x is computed differently: check the password hash, whether the username is included, and the order of salt and password"
+	case "k":
+		return "k is computed differently: check the hash function, padding of g and byte order, or whether k is a constant"
+	case "u":
+		return "u is computed differently: check the hash function, padding of A and B, and their order"
+	case "S":
+		return "S differs although its inputs match; check for a fault in the exponentiation"
+	case "K":
+		return "K is derived differently from S: check the hash function, padding of S, or SHA_Interleave"
+	case "M1", "M2":
+		return "the proof formula differs: check the hash function, the order of its inputs, and whether K or S is used"
+	}
+
+	return "unknown"
+}
+
+func reverseCopy(b []byte) []byte {
+	r := append([]byte(nil), b...)
+	reverse(r)
+	return r
+}
+
+var transcriptHashes = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"SHA-1", sha1.New},
+	{"SHA-256", sha256.New},
+	{"SHA-384", sha512.New384},
+	{"SHA-512", sha512.New},
+}
+
+// Recomputes value name from our values with other hash functions,
+// padding, byte orders and formulas, returning a description of the
+// variant that gives want.
+func (t *Transcript) find_variant(name string, want []byte) string {
+	number := func(n string) (*big.Int, bool) {
+		value, ok := t.Get(n)
+		if !ok {
+			return nil, false
+		}
+
+		b, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, false
+		}
+
+		v := t.config.decode(b)
+		return &v, true
+	}
+
+	for _, h := range transcriptHashes {
+		for _, order := range []ByteOrder{BigEndian, LittleEndian} {
+			for _, pad := range []bool{true, false} {
+				for _, hexhash := range []bool{false, true} {
+					c := *t.config
+					c.cache = new(configCache)
+					c.k = nil
+					c.hash = h.hash
+					c.order = order
+					c.pad_values = pad
+					c.hex_hashing = hexhash
+
+					desc := fmt.Sprintf("computed with %s, %s, %s padding to the length of N", h.name, orderName(order), map[bool]string{true: "with", false: "without"}[pad])
+					if hexhash {
+						desc += ", hashing hex strings"
+					}
+
+					if got := c.variant(t, name, number); got != nil {
+						for suffix, value := range got {
+							if bytes.Equal(value, want) {
+								return strings.TrimSpace(suffix + " " + desc)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+func orderName(order ByteOrder) string {
+	if order == LittleEndian {
+		return "little-endian"
+	}
+
+	return "big-endian"
+}
+
+// Returns the candidate values of name under c, keyed by a
+// description of the formula used.
+func (c *SRPConfig) variant(t *Transcript, name string, number func(string) (*big.Int, bool)) map[string][]byte {
+	biga, haveA := number("A")
+	bigb, haveB := number("B")
+	premaster, haveS := number("S")
+	salt, haveSalt := number("s")
+
+	switch name {
+	case "k":
+		k := c.calculate_k()
+		return map[string][]byte{"H(N | g)": c.element(&k)}
+	case "u":
+		if !haveA || !haveB {
+			return nil
+		}
+
+		u, swapped := c.calculate_u(biga, bigb), c.calculate_u(bigb, biga)
+		return map[string][]byte{"H(A | B)": c.element(&u), "H(B | A), in that order,": c.element(&swapped)}
+	case "x":
+		i, _ := t.Get("I")
+		p, haveP := t.Get("P")
+		if !haveP || !haveSalt {
+			return nil
+		}
+
+		variants := make(map[string][]byte)
+		for desc, credentials := range map[string]func(string, string) []byte{"H(s | H(p))": PasswordOnly, "H(s | H(I | \":\" | p))": UsernamePassword} {
+			c.h, c.hctx = SaltedHash(c.hash, c.order), nil
+			c.credentials = credentials
+			x := c.calculate_x(i, p, salt)
+			variants[desc] = c.element(&x)
+		}
+
+		return variants
+	case "K":
+		if !haveS {
+			return nil
+		}
+
+		return map[string][]byte{
+			"H(S)":              c.digest(c.hashable(premaster, false)),
+			"H(PAD(S))":         c.digest(c.hashable(premaster, true)),
+			"SHA_Interleave(S)": c.sha_interleave(c.element(premaster)),
+		}
+	case "M1", "M2":
+		i, _ := t.Get("I")
+		key, haveK := t.Get("K")
+		if !haveA || !haveB || !haveS || !haveK || !haveSalt {
+			return nil
+		}
+
+		k, _ := hex.DecodeString(key)
+
+		variants := make(map[string][]byte)
+		for _, proofs := range []ProofFormula{ProofRFC2945, ProofABS} {
+			c.proofs = proofs
+			m1 := c.calculate_m1(i, salt, biga, bigb, premaster, k)
+			desc := map[ProofFormula]string{ProofRFC2945: "the RFC 2945 proof", ProofABS: "H(A | B | S) style proof"}[proofs]
+			if name == "M1" {
+				variants[desc] = m1
+			} else {
+				variants[desc] = c.calculate_m2(biga, premaster, m1, k)
+			}
+		}
+
+		return variants
+	}
+
+	return nil
+}
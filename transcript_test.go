@@ -0,0 +1,115 @@
+package libgosrp
+
+import (
+	"strings"
+	"testing"
+)
+
+// Runs a handshake with fixed a, b and salt, recording both sides.
+func testtranscripts(t *testing.T, client, server *SRPConfig) (*Transcript, *Transcript) {
+	client.abgen = fixedgen("60975527035CF2AD1989806F0407210BC81EDC04E2762A56AFD529DDDA2D4393")
+	server.abgen = fixedgen("E487CB59D31AC550471E81F00F6928E01DDA08E974A004F49E61F5D105284D20")
+	server.sgen = testgen
+
+	var v Verifier
+	if _, err := v.New("alice", "password123", 16, server); err != nil {
+		t.Fatal(err)
+	}
+
+	var ct, st Transcript
+	csess, ssess := new(SRPClientSession), new(SRPSession)
+	csess.SetTranscript(&ct)
+	ssess.SetTranscript(&st)
+
+	if _, err := csess.New("alice", client); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ssess.New(v, server); err != nil {
+		t.Fatal(err)
+	}
+
+	hello, _ := csess.Hello()
+	if err := ssess.ReadHello(hello); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, _ := ssess.Challenge()
+	if err := csess.ReadChallenge(challenge, "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	return &ct, &st
+}
+
+func TestTranscripts(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+	ct, st := testtranscripts(t, config, config)
+
+	if m := ct.Compare(st); m != nil {
+		t.Errorf("Error: transcripts of a good handshake differ: %v", m)
+	}
+
+	for _, name := range []string{"x", "S", "K", "P", "a", "M1"} {
+		if _, ok := ct.Get(name); !ok {
+			t.Errorf("Error: client transcript lacks %s.", name)
+		}
+	}
+
+	//a client that doesn't pad g when computing k
+	unpadded, _ := GetProfile(ProfileRFC5054)
+	unpadded.SetPad(false)
+	ct, _ = testtranscripts(t, unpadded, config)
+
+	m := st.Compare(ct)
+	if m == nil || m.Name != "k" || !strings.Contains(m.Cause, "without padding") {
+		t.Errorf("Error: wrong diagnosis: %v", m)
+	}
+
+	ct.Redact()
+	if _, ok := ct.Get("x"); ok {
+		t.Error("Error: x survived redaction.")
+	}
+
+	//a nil transcript turns recording off
+	csess, ssess := new(SRPClientSession), new(SRPSession)
+	csess.SetTranscript(ct)
+	csess.SetTranscript(nil)
+	ssess.SetTranscript(nil)
+	if _, err := csess.New("alice", config); err != nil || csess.transcript != nil {
+		t.Errorf("Error: client session still recording after SetTranscript(nil): %v", err)
+	}
+}
+
+func TestParseTranscript(t *testing.T) {
+	config, _ := GetProfile(ProfileRFC5054)
+	_, st := testtranscripts(t, config, config)
+
+	u, _ := st.Get("u")
+	b, _ := st.Get("B")
+
+	//u in lower case with separators, and B byte-reversed
+	var pasted strings.Builder
+	pasted.WriteString("# from another implementation\n")
+	pasted.WriteString("Salt: 0xbeb25379d1a8581eb5a727673a2441ee\n")
+	pasted.WriteString("u = " + strings.ToLower(u[:8]) + " " + strings.ToLower(u[8:]) + "\n")
+	reversed := make([]byte, 0, len(b))
+	for j := len(b); j > 0; j -= 2 {
+		reversed = append(reversed, b[j-2:j]...)
+	}
+	pasted.WriteString("B = " + string(reversed) + "\n")
+
+	theirs, err := ParseTranscript([]byte(pasted.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := st.Compare(theirs)
+	if m == nil || m.Name != "B" || !strings.HasPrefix(m.Cause, "byte order") {
+		t.Errorf("Error: wrong diagnosis: %v", m)
+	}
+
+	if _, err = ParseTranscript([]byte("no separator")); err == nil {
+		t.Error("Error: parsed a malformed transcript.")
+	}
+}
@@ -0,0 +1,74 @@
+package libgosrp
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Smallest N accepted by Validate by default, in bits.
+const MinGroupBits = 2048
+
+// Number of Miller-Rabin rounds used when testing N and (N-1)/2.
+const primalityRounds = 32
+
+type ErrorInvalidGroup string
+
+func (e ErrorInvalidGroup) Error() string {
+	return fmt.Sprintf("Invalid SRP group: %s.", string(e))
+}
+
+// Returns true if gp is one of the groups built into this package.
+// Groups added with RegisterGroup are not included.
+func (gp *SRPGroupParameters) IsKnown() bool {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	name, ok := registry.byfingerprint[gp.Fingerprint()]
+	return ok && registry.byname[name].builtin
+}
+
+// Checks group parameters received from an untrusted source: N must
+// be a safe prime of at least minbits bits, and g must generate a
+// subgroup of order (N-1)/2 or N-1. Groups built into this package
+// are accepted without the (slow) primality tests, provided they meet
+// minbits.
+func (gp *SRPGroupParameters) Validate(minbits int) error {
+	n := &gp.N
+
+	if n.BitLen() < minbits {
+		return ErrorInvalidGroup(fmt.Sprintf("N is %d bits, at least %d required", n.BitLen(), minbits))
+	}
+
+	if gp.IsKnown() {
+		return nil
+	}
+
+	return gp.check_safe_prime(primalityRounds)
+}
+
+func (gp *SRPGroupParameters) check_safe_prime(rounds int) error {
+	n, g := &gp.N, &gp.G
+
+	if n.Bit(0) == 0 || !n.ProbablyPrime(rounds) {
+		return ErrorInvalidGroup("N is not prime")
+	}
+
+	q := new(big.Int).Rsh(n, 1)
+	if !q.ProbablyPrime(rounds) {
+		return ErrorInvalidGroup("N is not a safe prime")
+	}
+
+	nminus1 := new(big.Int).Sub(n, big.NewInt(1))
+	if g.Cmp(big.NewInt(1)) <= 0 || g.Cmp(nminus1) >= 0 {
+		return ErrorInvalidGroup("g must be between 1 and N-1")
+	}
+
+	//N = 2q+1 with q prime, so g has order 2, q or 2q. Order 2
+	//was ruled out above; make sure of the rest.
+	t := new(big.Int).Exp(g, q, n)
+	if t.Cmp(big.NewInt(1)) != 0 && t.Cmp(nminus1) != 0 {
+		return ErrorInvalidGroup("g does not generate a large subgroup")
+	}
+
+	return nil
+}
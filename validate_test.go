@@ -0,0 +1,50 @@
+package libgosrp
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestValidateKnownGroups(t *testing.T) {
+	for _, gp := range TrustedGroups() {
+		if !gp.IsKnown() {
+			t.Errorf("Error: %d-bit group not recognized.", gp.N.BitLen())
+		}
+
+		if err := gp.Validate(1024); err != nil {
+			t.Error(err)
+		}
+	}
+
+	gp, _ := GetGroupParameters(1024)
+	if err := gp.Validate(MinGroupBits); err == nil {
+		t.Error("Error: 1024-bit group accepted with a 2048-bit minimum.")
+	}
+}
+
+func TestValidateUnknownGroups(t *testing.T) {
+	known, _ := GetGroupParameters(1024)
+
+	var notprime, badg SRPGroupParameters
+	notprime.N.Add(&known.N, big.NewInt(2))
+	notprime.G.SetInt64(2)
+	badg.N.Set(&known.N)
+	badg.G.Sub(&known.N, big.NewInt(1))
+
+	//23 = 2*11+1, a safe prime
+	var safe, unsafe SRPGroupParameters
+	safe.N.SetInt64(23)
+	safe.G.SetInt64(5)
+	unsafe.N.SetInt64(29)
+	unsafe.G.SetInt64(2)
+
+	for name, gp := range map[string]SRPGroupParameters{"composite N": notprime, "g = N-1": badg, "unsafe prime": unsafe} {
+		if err := gp.Validate(0); err == nil {
+			t.Errorf("Error: group with %s accepted.", name)
+		}
+	}
+
+	if err := safe.Validate(0); err != nil {
+		t.Error(err)
+	}
+}
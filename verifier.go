@@ -1,13 +1,38 @@
 package libgosrp
 
 import (
+	"context"
+	"fmt"
 	"math/big"
+	"strings"
+	"time"
 )
 
+// Prefix of the profile recorded in verifiers made with a config set
+// up by hand.
+const customProfile = "custom:"
+
+// Format version of Verifier records written by this package.
+// Records with version 0 predate the Group and Profile fields.
+const VerifierVersion = 1
+
 type Verifier struct {
 	I        string  //Username
 	Salt     big.Int //salt
 	Verifier big.Int //verifier
+	//Identifies the group and profile the verifier was computed
+	//with. Group is the group's fingerprint, so it means the same
+	//whichever groups are registered; records made before may hold
+	//a registered name instead. See SRPGroupParameters.Fingerprint()
+	//and SRPConfig.Profile(). Configs set up by hand record "custom:"
+	//and a fingerprint of their password hash as the profile.
+	Group   string
+	Profile string
+	Created time.Time
+	Version int
+	//Id of the pepper key that sealed the verifier, if any. See
+	//Pepper.
+	Pepper string
 }
 
 // Create an SRP verifier, given a password p, and the length of the desired salt,
@@ -18,10 +43,19 @@ type Verifier struct {
 // with them separately. The function handed to this should be a wrapper function
 // that takes the password and salt as its 1st and 2nd argument respectively.
 func (v *Verifier) New(user, p string, slen uint, server *SRPConfig) (*Verifier, error) {
+	return v.NewContext(context.Background(), user, p, slen, server)
+}
+
+// As New, but gives up with ctx.Err() once ctx is done. The password
+// hash is only stopped partway if the config has a context hash; see
+// SRPConfig.SetContextHash.
+func (v *Verifier) NewContext(ctx context.Context, user, p string, slen uint, server *SRPConfig) (*Verifier, error) {
 	//Create random salt
 	var err error
 
-	v.I = user
+	//starts a fresh record, as v may be an old one being replaced,
+	//such as on a password change
+	*v = Verifier{I: user}
 	v.Salt, err = server.sgen(slen)
 
 	//check for errors, make sure salt is of the desired length.
@@ -30,10 +64,92 @@ func (v *Verifier) New(user, p string, slen uint, server *SRPConfig) (*Verifier,
 	}
 
 	//run hash function on password and salt
-	x := server.h([]byte(p), v.Salt.Bytes())
+	x, err := server.calculate_x_context(ctx, user, p, &v.Salt)
+	defer wipe(&x)
+	if err != nil {
+		return &Verifier{}, err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return &Verifier{}, err
+	}
 
 	//create verifier v with hash and g (g**x % N)
-	v.Verifier.Exp(&server.gp.G, &x, &server.gp.N)
+	v.Verifier = server.exp_g(&x, server.x_bits())
+
+	v.Group = server.gp.Fingerprint()
+	v.Profile = server.verifier_profile()
+	//whole seconds, as kept by the binary encoding
+	v.Created = time.Now().UTC().Truncate(time.Second)
+	v.Version = VerifierVersion
+
+	if server.pepper != nil {
+		if err = server.pepper.Seal(v, server); err != nil {
+			return &Verifier{}, err
+		}
+	}
 
 	return v, nil
 }
+
+// Checks that the verifier was computed with the group and profile
+// of config. Version 0 records carry neither and are always accepted;
+// later records without a profile can't be checked, and are refused.
+func (v *Verifier) Check(config *SRPConfig) error {
+	if v.Version > VerifierVersion {
+		return ErrorVerifierMismatch(fmt.Sprintf("record format version %d is newer than this package supports", v.Version))
+	}
+
+	if v.Version == 0 {
+		return nil
+	}
+
+	if v.Profile == "" {
+		return ErrorVerifierMismatch("verifier does not record its profile")
+	}
+
+	if !config.gp.identified_by(v.Group) {
+		return ErrorVerifierMismatch(fmt.Sprintf("verifier uses group %s, session uses %s", v.Group, config.GroupID()))
+	}
+
+	if profile := config.verifier_profile(); v.Profile != profile {
+		return ErrorVerifierMismatch(fmt.Sprintf("verifier uses profile %q, session uses %q", v.Profile, profile))
+	}
+
+	return nil
+}
+
+// Returns a new SRPConfig matching the verifier's profile and group,
+// for use when a database holds verifiers from more than one config.
+// Only verifiers made from a named profile can be handled this way.
+func (v *Verifier) Config() (*SRPConfig, error) {
+	if v.Profile == "" || strings.HasPrefix(v.Profile, customProfile) {
+		return nil, ErrorVerifierMismatch("verifier was not made from a named profile")
+	}
+
+	config, err := GetProfile(v.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Group != "" && !config.gp.identified_by(v.Group) {
+		gp, err := LookupGroup(v.Group)
+		if err != nil {
+			_, gp, err = LookupGroupByFingerprint(v.Group)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		config.gp = gp
+	}
+
+	return config, nil
+}
+
+type ErrorVerifierMismatch string
+
+func (e ErrorVerifierMismatch) Error() string {
+	return fmt.Sprintf("Verifier does not match SRP configuration: %s.", string(e))
+}
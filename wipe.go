@@ -0,0 +1,30 @@
+package libgosrp
+
+import (
+	"math/big"
+)
+
+// Overwrites the words of n and sets it to 0. Copies made by earlier
+// arithmetic on n are out of reach, so secrets should be kept in as
+// few big.Ints as possible.
+func wipe(n *big.Int) {
+	words := n.Bits()
+	words = words[:cap(words)]
+	for i := range words {
+		words[i] = 0
+	}
+
+	n.SetBits(words[:0])
+}
+
+func wipe_bytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func wipe_words(w []uint) {
+	for i := range w {
+		w[i] = 0
+	}
+}